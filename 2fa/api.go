@@ -0,0 +1,335 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/tristanwietsma/otp"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type apiCommand struct {
+	addr   string
+	socket string
+	token  string
+}
+
+func (c *apiCommand) Name() string {
+	return "serve-api"
+}
+
+func (c *apiCommand) FlagSet() *flag.FlagSet {
+	fs := newFlagSet(c.Name())
+	fs.StringVar(&c.addr, "addr", "127.0.0.1:8043", "")
+	fs.StringVar(&c.socket, "socket", "", "")
+	fs.StringVar(&c.token, "token", os.Getenv("TWOFA_API_TOKEN"), "")
+	return fs
+}
+
+func (c *apiCommand) Run(args []string) error {
+	fs := c.FlagSet()
+	if err := fs.Parse(args); err != nil {
+		return errUsage("%v", err)
+	}
+	if fs.NArg() != 0 {
+		return errUsage("serve-api takes no arguments")
+	}
+
+	cfg, err := loadCfg()
+	if err != nil {
+		return err
+	}
+
+	// without peer credentials, a socket is only usable with a token
+	if c.token == "" && (c.socket == "" || !havePeerUID) {
+		if c.token, err = newToken(); err != nil {
+			return fmt.Errorf("unable to generate access token: %v", err)
+		}
+		fmt.Fprintln(os.Stderr, "bearer token "+c.token)
+	}
+
+	var ln net.Listener
+	if c.socket != "" {
+		ln, err = listenPrivate(c.socket)
+	} else {
+		ln, err = net.Listen("tcp", c.addr)
+	}
+	if err != nil {
+		return err
+	}
+	defer ln.Close()
+
+	srv := &http.Server{
+		Handler:           newAPI(cfg, c.token),
+		ReadHeaderTimeout: 10 * time.Second,
+		ConnContext:       peerContext,
+	}
+
+	u := "http://" + ln.Addr().String()
+	if c.socket != "" {
+		u = "unix:" + c.socket
+	}
+	if structured() {
+		if err := newEmitter().emit(urlRecord{u}); err != nil {
+			return err
+		}
+	} else {
+		fmt.Fprintln(stdout, "serving API at "+u)
+	}
+
+	failed := make(chan error, 1)
+	go func() { failed <- srv.Serve(ln) }()
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+	select {
+	case err := <-failed:
+		return err
+	case <-interrupt:
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return srv.Shutdown(ctx)
+}
+
+// listenPrivate listens on a Unix socket at path that only the user can
+// connect to. The socket is created in a private directory and made 0600
+// before being linked into place, so it is never reachable with the
+// permissions of the umask.
+func listenPrivate(path string) (net.Listener, error) {
+	dir, err := os.MkdirTemp(filepath.Dir(path), ".2fa-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	tmp := filepath.Join(dir, "s")
+	ln, err := net.Listen("unix", tmp)
+	if err != nil {
+		return nil, err
+	}
+	// the socket is removed by path on close, and it is about to move
+	ln.(*net.UnixListener).SetUnlinkOnClose(false)
+	if err := os.Chmod(tmp, 0600); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	// unlike a rename, a link fails rather than replace an existing file
+	if err := os.Link(tmp, path); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return unlinkListener{ln, path}, nil
+}
+
+// unlinkListener removes its socket when closed.
+type unlinkListener struct {
+	net.Listener
+	path string
+}
+
+func (l unlinkListener) Close() error {
+	err := l.Listener.Close()
+	os.Remove(l.path)
+	return err
+}
+
+func (c *apiCommand) Usage() {
+	usage := "    serve-api   serve codes over a local REST API"
+	fmt.Println(usage)
+}
+
+func (c *apiCommand) Help() {
+	help := "\n" + c.Name() + " usage:\n\n    2fa " + c.Name() + " [-addr host:port | -socket path] [-token token]\n\n"
+	help += "    Serves the keys stored in " + getCfgPath() + " over HTTP:\n\n"
+	help += "    GET  /keys                   list keys\n"
+	help += "    GET  /keys/{label}/code      calculate a key's current code\n"
+	help += "    POST /keys/{label}/verify    check a code, sent as {\"code\": \"123456\"}\n\n"
+	help += "    Requests must carry an \"Authorization: Bearer\" header with the token, which\n"
+	help += "    is generated and printed if not given. Over a Unix socket on Linux, requests\n"
+	help += "    from processes run by the same user are accepted without it, and no token\n"
+	help += "    is generated.\n\n"
+	help += "    -addr       listen address (default 127.0.0.1:8043)\n"
+	help += "    -socket     listen on a Unix socket instead\n"
+	help += "    -token      bearer token; defaults to $TWOFA_API_TOKEN\n"
+	fmt.Println(help)
+}
+
+type peerKey struct{}
+
+// peerContext records the uid of the process on the other end of a Unix
+// socket, where the platform supports it.
+func peerContext(ctx context.Context, c net.Conn) context.Context {
+	if uid, err := peerUID(c); err == nil {
+		return context.WithValue(ctx, peerKey{}, uid)
+	}
+	return ctx
+}
+
+// api serves the keys of a config over HTTP.
+type api struct {
+	cfg   *config
+	token string
+}
+
+func newAPI(cfg *config, token string) http.Handler {
+	return &api{cfg, token}
+}
+
+func (a *api) authorized(r *http.Request) bool {
+	if uid, ok := r.Context().Value(peerKey{}).(int); ok && uid == os.Getuid() {
+		return true
+	}
+	auth := r.Header.Get("Authorization")
+	if a.token == "" || !strings.HasPrefix(auth, "Bearer ") {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(auth[len("Bearer "):]), []byte(a.token)) == 1
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
+
+func (a *api) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !a.authorized(r) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.EscapedPath(), "/"), "/")
+	switch {
+	case len(parts) == 1 && parts[0] == "keys":
+		a.route(w, r, "GET", a.listKeys, "")
+	case len(parts) == 3 && parts[0] == "keys" && parts[2] == "code":
+		a.route(w, r, "GET", a.getCode, parts[1])
+	case len(parts) == 3 && parts[0] == "keys" && parts[2] == "verify":
+		a.route(w, r, "POST", a.verify, parts[1])
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+// route checks the method and label before calling the handler.
+func (a *api) route(w http.ResponseWriter, r *http.Request, method string, handle func(http.ResponseWriter, *http.Request, string), label string) {
+	if r.Method != method {
+		w.Header().Set("Allow", method)
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if label != "" {
+		var err error
+		if label, err = url.PathUnescape(label); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid label")
+			return
+		}
+		if _, ok := a.cfg.Key[label]; !ok {
+			writeError(w, http.StatusNotFound, "no key labeled "+label)
+			return
+		}
+	}
+	handle(w, r, label)
+}
+
+func (a *api) listKeys(w http.ResponseWriter, r *http.Request, _ string) {
+	records := []keyRecord{}
+	for _, label := range a.cfg.labels() {
+		records = append(records, newKeyRecord(label, a.cfg.Key[label]))
+	}
+	writeJSON(w, http.StatusOK, records)
+}
+
+// otpKey builds the otp.Key for a label.
+func (a *api) otpKey(label string) (*otp.Key, error) {
+	k := a.cfg.Key[label]
+	return otp.NewTOTPKey(label, k.Secret, k.Issuer, otp.Hashes[0], 6, int(k.period()))
+}
+
+func (a *api) getCode(w http.ResponseWriter, r *http.Request, label string) {
+	k, err := a.otpKey(label)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	iv, _ := otp.GetInterval(int64(k.Period))
+	code, err := k.GetCode(iv)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	rec := newKeyRecord(label, a.cfg.Key[label])
+	expires := time.Unix((iv+1)*int64(k.Period), 0).UTC()
+	rec.Code, rec.ExpiresAt = code, &expires
+	writeJSON(w, http.StatusOK, rec)
+}
+
+// verifyRequest is the body of a verify request.
+type verifyRequest struct {
+	Code string `json:"code"`
+}
+
+// verifyResponse reports whether a code was valid.
+type verifyResponse struct {
+	Label string `json:"label"`
+	Valid bool   `json:"valid"`
+}
+
+func (a *api) verify(w http.ResponseWriter, r *http.Request, label string) {
+	var req verifyRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1024)).Decode(&req); err != nil || req.Code == "" {
+		writeError(w, http.StatusBadRequest, `expected {"code": "..."}`)
+		return
+	}
+	k, err := a.otpKey(label)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	valid, err := verifyCode(k, req.Code)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, verifyResponse{label, valid})
+}
+
+// verifyCode checks the code against the current period and its neighbors,
+// allowing for clock skew.
+func verifyCode(k *otp.Key, code string) (bool, error) {
+	if k.Period < 1 {
+		return false, errors.New("key has no period")
+	}
+	iv, _ := otp.GetInterval(int64(k.Period))
+	valid := false
+	for step := iv - 1; step <= iv+1; step++ {
+		expected, err := k.GetCode(step)
+		if err != nil {
+			return false, err
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			valid = true
+		}
+	}
+	return valid, nil
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/tristanwietsma/otp"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var apiCfg = &config{
+	Key: map[string]key{
+		"gh":                       key{Issuer: "GitHub", Secret: "MFRGGZDFMZTWQ2LK"},
+		"Example:alice@google.com": key{Issuer: "Example", Secret: "NAR5XTDD3EQU22YU", Period: 60},
+		"broken":                   key{Secret: "abc123"},
+	},
+}
+
+func apiRequest(t *testing.T, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer t0ken")
+	w := httptest.NewRecorder()
+	newAPI(apiCfg, "t0ken").ServeHTTP(w, req)
+	return w
+}
+
+func TestAPIUnauthorized(t *testing.T) {
+	for _, auth := range []string{"", "Bearer wrong", "t0ken"} {
+		req := httptest.NewRequest("GET", "/keys", nil)
+		req.Header.Set("Authorization", auth)
+		w := httptest.NewRecorder()
+		newAPI(apiCfg, "t0ken").ServeHTTP(w, req)
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("Expected 401 for %q, got %v", auth, w.Code)
+		}
+	}
+}
+
+func TestAPIListKeys(t *testing.T) {
+	w := apiRequest(t, "GET", "/keys", "")
+	var records []keyRecord
+	if err := json.Unmarshal(w.Body.Bytes(), &records); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("Bad response %v: %v", w.Code, err)
+	}
+	if len(records) != 3 || records[0].Label != "Example:alice@google.com" || records[0].Period != 60 {
+		t.Errorf("Unexpected keys: %+v", records)
+	}
+}
+
+func TestAPIGetCode(t *testing.T) {
+	w := apiRequest(t, "GET", "/keys/Example:alice@google.com/code", "")
+	var rec keyRecord
+	if err := json.Unmarshal(w.Body.Bytes(), &rec); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("Bad response %v: %v", w.Code, err)
+	}
+	iv, _ := otp.GetInterval(60)
+	code, _ := otp.GetCode("NAR5XTDD3EQU22YU", iv, otp.Hashes[0], 6)
+	if rec.Code != code || rec.ExpiresAt == nil || rec.ExpiresAt.Unix() != (iv+1)*60 {
+		t.Errorf("Unexpected code record: %+v", rec)
+	}
+
+	if w := apiRequest(t, "GET", "/keys/missing/code", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a missing key, got %v", w.Code)
+	}
+	if w := apiRequest(t, "GET", "/keys/broken/code", ""); w.Code != http.StatusInternalServerError {
+		t.Errorf("Expected 500 for a broken key, got %v", w.Code)
+	}
+	if w := apiRequest(t, "POST", "/keys/gh/code", ""); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405, got %v", w.Code)
+	}
+}
+
+func TestAPIVerify(t *testing.T) {
+	iv, _ := otp.GetInterval(30)
+	code, _ := otp.GetCode("MFRGGZDFMZTWQ2LK", iv, otp.Hashes[0], 6)
+
+	var resp verifyResponse
+	w := apiRequest(t, "POST", "/keys/gh/verify", `{"code": "`+code+`"}`)
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || !resp.Valid {
+		t.Errorf("Current code rejected: %v %v", w.Body.String(), err)
+	}
+
+	stale, _ := otp.GetCode("MFRGGZDFMZTWQ2LK", iv-5, otp.Hashes[0], 6)
+	w = apiRequest(t, "POST", "/keys/gh/verify", `{"code": "`+stale+`"}`)
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.Valid {
+		t.Errorf("Stale code accepted: %v %v", w.Body.String(), err)
+	}
+
+	if w := apiRequest(t, "POST", "/keys/gh/verify", "nonsense"); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a bad body, got %v", w.Code)
+	}
+}
+
+func TestListenPrivate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "api.sock")
+	ln, err := listenPrivate(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode()&os.ModeSocket == 0 || fi.Mode().Perm() != 0600 {
+		t.Errorf("Unexpected socket mode %v", fi.Mode())
+	}
+
+	go func() {
+		if c, err := ln.Accept(); err == nil {
+			c.Close()
+		}
+	}()
+	c, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+
+	if _, err := listenPrivate(path); err == nil {
+		t.Error("Existing socket was replaced")
+	}
+	ln.Close()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Socket not removed on close: %v", err)
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 0 {
+		t.Errorf("Private directories left behind: %v", entries)
+	}
+}
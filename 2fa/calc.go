@@ -1,51 +1,205 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"github.com/tristanwietsma/otp"
+	"os"
+	"os/signal"
+	"time"
 )
 
-func getCode(secret string) (string, int64) {
-	iv, rem := otp.GetInterval(30)
-	code, err := otp.GetCode(secret, iv, otp.Hashes[0], 6)
+func getCode(label string, k key) (string, int64, error) {
+	iv, rem := otp.GetInterval(k.period())
+	code, err := otp.GetCode(k.Secret, iv, otp.Hashes[0], 6)
 	if err != nil {
-		return "calculation failed", -1
+		return "", 0, keyError{label, err}
 	}
-	return code, rem
+	return code, rem, nil
 }
 
-type calcCommand struct{}
+type calcCommand struct {
+	watching bool
+	timeout  time.Duration
+	copying  bool
+	fresh    int64
+	group    string
+	tags     tagsFlag
+}
 
-func (c calcCommand) Name() string {
+func (c *calcCommand) Name() string {
 	return "calc"
 }
 
-func (c calcCommand) Run(args []string) bool {
-	if len(args) != 1 {
-		return false
+func (c *calcCommand) FlagSet() *flag.FlagSet {
+	fs := newFlagSet(c.Name())
+	fs.BoolVar(&c.watching, "watch", false, "")
+	fs.DurationVar(&c.timeout, "timeout", 0, "")
+	fs.BoolVar(&c.copying, "copy", false, "")
+	fs.Int64Var(&c.fresh, "fresh", 5, "")
+	fs.StringVar(&c.group, "group", "", "")
+	c.tags = nil
+	fs.Var(&c.tags, "tag", "")
+	return fs
+}
+
+func (c *calcCommand) Run(args []string) error {
+	fs := c.FlagSet()
+	if err := fs.Parse(args); err != nil {
+		return errUsage("%v", err)
+	}
+
+	filtered := c.group != "" || len(c.tags) > 0
+	if fs.NArg() > 1 || (fs.NArg() == 0 && !filtered) {
+		return errUsage("calc takes a label, or -group or -tag")
+	}
+	cfg, err := loadCfg()
+	if err != nil {
+		return err
+	}
+	if filtered {
+		cfg = cfg.subset(cfg.filter(c.group, c.tags))
+	}
+
+	if fs.NArg() == 0 {
+		if c.copying {
+			return errUsage("-copy takes a single label")
+		}
+		if len(cfg.Key) == 0 {
+			return matchError{"no keys match the group and tags"}
+		}
+		if c.watching {
+			return watch(cfg, cfg.labels(), c.timeout)
+		}
+		return calcAll(cfg)
+	}
+
+	label, err := resolve(cfg, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	k := cfg.Key[label]
+
+	if c.watching {
+		return watch(cfg, []string{label}, c.timeout)
+	}
+
+	if c.copying {
+		return copyCode(newClipboard(), k, label, c.fresh)
+	}
+
+	if structured() {
+		r, err := newKeyRecord(label, k).withCode(k)
+		if err != nil {
+			return err
+		}
+		return newEmitter().emit(r)
+	}
+
+	code, rem, err := getCode(label, k)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "%v (%v seconds)\n", code, rem)
+	return nil
+}
+
+// calcAll prints the codes for every key in the config.
+func calcAll(cfg *config) error {
+	e := newEmitter()
+	width := 0
+	for label := range cfg.Key {
+		if len(label) > width {
+			width = len(label)
+		}
 	}
-	cfg := getCfg()
-	k, ok := cfg.Key[args[0]]
-	if !ok {
-		return false
+
+	for _, label := range cfg.labels() {
+		k := cfg.Key[label]
+		if structured() {
+			r, err := newKeyRecord(label, k).withCode(k)
+			if err != nil {
+				return err
+			}
+			if err := e.emit(r); err != nil {
+				return err
+			}
+			continue
+		}
+		code, rem, err := getCode(label, k)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "%-*v  %v (%v seconds)\n", width, label, code, rem)
 	}
-	code, rem := getCode(k.Secret)
-	if rem > 0 {
-		fmt.Printf("%v (%v seconds)\n", code, rem)
-		return true
+	return nil
+}
+
+// freshCode returns the key's code and seconds remaining, first waiting for
+// the next code if fewer than fresh seconds remain.
+func freshCode(label string, k key, fresh int64) (string, int64, error) {
+	code, rem, err := getCode(label, k)
+	if err != nil || rem >= fresh {
+		return code, rem, err
 	}
+	fmt.Fprintf(os.Stderr, "waiting %v seconds for the next code\n", rem)
+	time.Sleep(time.Until(k.expires()))
+	return getCode(label, k)
+}
 
-	fmt.Printf("%v; verify %v is correctly formatted", code, getCfgPath())
-	return true
+// copyCode copies a fresh code for the key to the clipboard and clears the
+// clipboard once the code expires.
+func copyCode(clip clipboard, k key, label string, fresh int64) error {
+	code, rem, err := freshCode(label, k, fresh)
+	if err != nil {
+		return err
+	}
+	expires := k.expires().UTC()
+
+	if err := clip.Write(code); err != nil {
+		return fmt.Errorf("unable to copy code: %v", err)
+	}
+	if structured() {
+		r := newKeyRecord(label, k)
+		r.Code, r.ExpiresAt = code, &expires
+		if err := newEmitter().emit(r); err != nil {
+			return err
+		}
+	} else {
+		fmt.Fprintf(stdout, "%v copied; clearing in %v seconds\n", code, rem)
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+	select {
+	case <-interrupt:
+	case <-time.After(time.Until(expires)):
+	}
+
+	if _, err := clearIfUnchanged(clip, code); err != nil {
+		return fmt.Errorf("unable to clear clipboard: %v", err)
+	}
+	return nil
 }
 
-func (c calcCommand) Usage() {
+func (c *calcCommand) Usage() {
 	usage := "    calc        calculate a one-time password"
 	fmt.Println(usage)
 }
 
-func (c calcCommand) Help() {
-	help := "\n" + c.Name() + " usage:\n\n    2fa " + c.Name() + " label\n\n"
+func (c *calcCommand) Help() {
+	help := "\n" + c.Name() + " usage:\n\n    2fa " + c.Name() + " [-watch [-timeout duration]] [-copy [-fresh seconds]] label\n"
+	help += "    2fa " + c.Name() + " [-watch [-timeout duration]] [-group group] [-tag tag]... [label]\n\n"
 	help += "    The label is associated with a key and defined in " + getCfgPath() + ".\n"
+	help += "    If no label matches exactly, labels and issuers starting with, containing,\n"
+	help += "    or sharing the letters of the given label in order are tried in turn.\n"
+	help += "    Without a label, the codes for every key in the group are shown.\n\n"
+	help += "    -watch      redraw the code in place until interrupted\n"
+	help += "    -timeout    stop watching after a duration, such as 90s or 5m\n"
+	help += "    -group      only consider keys in the group or a folder beneath it\n"
+	help += "    -tag        only consider keys with the tag; may be repeated\n"
+	help += "    -copy       copy the code to the clipboard and clear it once expired\n"
+	help += "    -fresh      with -copy, wait for the next code if fewer seconds remain (default 5)\n"
 	fmt.Println(help)
 }
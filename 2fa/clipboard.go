@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// clipboard reads and writes the system clipboard.
+type clipboard interface {
+	Read() (string, error)
+	Write(string) error
+}
+
+// cmdClipboard shells out to a clipboard utility such as xclip.
+type cmdClipboard struct {
+	copy  []string
+	paste []string
+	clear []string // used in place of copy to write an empty value, if set
+}
+
+func (c cmdClipboard) Read() (string, error) {
+	out, err := exec.Command(c.paste[0], c.paste[1:]...).Output()
+	return strings.TrimSuffix(string(out), "\n"), err
+}
+
+func (c cmdClipboard) Write(s string) error {
+	if s == "" && c.clear != nil {
+		return exec.Command(c.clear[0], c.clear[1:]...).Run()
+	}
+	cmd := exec.Command(c.copy[0], c.copy[1:]...)
+	cmd.Stdin = strings.NewReader(s)
+	return cmd.Run()
+}
+
+// errWriteOnly is returned by clipboards that can't be read back.
+var errWriteOnly = errors.New("the terminal clipboard can not be read")
+
+// osc52Clipboard sets the clipboard of the controlling terminal using the
+// OSC 52 escape sequence. This works over ssh, but the clipboard can not be
+// read back.
+type osc52Clipboard struct {
+	w io.Writer
+}
+
+func (c osc52Clipboard) Read() (string, error) {
+	return "", errWriteOnly
+}
+
+func (c osc52Clipboard) Write(s string) error {
+	_, err := fmt.Fprintf(c.w, "\033]52;c;%v\a", base64.StdEncoding.EncodeToString([]byte(s)))
+	return err
+}
+
+func haveCommands(names ...string) bool {
+	for _, name := range names {
+		if _, err := exec.LookPath(name); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// newClipboard picks a clipboard for the current session, preferring
+// wl-copy under Wayland, then xclip or xsel under X, then OSC 52.
+func newClipboard() clipboard {
+	if os.Getenv("WAYLAND_DISPLAY") != "" && haveCommands("wl-copy", "wl-paste") {
+		return cmdClipboard{
+			copy:  []string{"wl-copy"},
+			paste: []string{"wl-paste", "--no-newline"},
+			clear: []string{"wl-copy", "--clear"},
+		}
+	}
+	if os.Getenv("DISPLAY") != "" {
+		if haveCommands("xclip") {
+			return cmdClipboard{
+				copy:  []string{"xclip", "-selection", "clipboard"},
+				paste: []string{"xclip", "-selection", "clipboard", "-o"},
+			}
+		}
+		if haveCommands("xsel") {
+			return cmdClipboard{
+				copy:  []string{"xsel", "--clipboard", "--input"},
+				paste: []string{"xsel", "--clipboard", "--output"},
+				clear: []string{"xsel", "--clipboard", "--clear"},
+			}
+		}
+	}
+	return osc52Clipboard{os.Stderr}
+}
+
+// clearIfUnchanged empties the clipboard if it still holds the code. A
+// write-only clipboard is emptied regardless, since the code may still be in
+// it; any other that can't be read is left alone.
+func clearIfUnchanged(clip clipboard, code string) (bool, error) {
+	current, err := clip.Read()
+	if errors.Is(err, errWriteOnly) {
+		current, err = code, nil
+	}
+	if err != nil || current != code {
+		return false, nil
+	}
+	if err := clip.Write(""); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeClipboard struct {
+	value    string
+	writable bool
+	readable bool
+	writes   []string
+}
+
+func (c *fakeClipboard) Read() (string, error) {
+	if !c.readable {
+		return "", errors.New("unreadable")
+	}
+	return c.value, nil
+}
+
+func (c *fakeClipboard) Write(s string) error {
+	if !c.writable {
+		return errors.New("unwritable")
+	}
+	c.value = s
+	c.writes = append(c.writes, s)
+	return nil
+}
+
+func TestClearIfUnchanged(t *testing.T) {
+	clip := &fakeClipboard{value: "123456", writable: true, readable: true}
+	if cleared, err := clearIfUnchanged(clip, "123456"); !cleared || err != nil {
+		t.Errorf("Clipboard holding the code was not cleared: %v", err)
+	}
+	if clip.value != "" {
+		t.Errorf("Clipboard still holds %q", clip.value)
+	}
+}
+
+func TestClearIfChanged(t *testing.T) {
+	clip := &fakeClipboard{value: "something else", writable: true, readable: true}
+	if cleared, _ := clearIfUnchanged(clip, "123456"); cleared {
+		t.Error("Clipboard was cleared after it changed")
+	}
+	if clip.value != "something else" {
+		t.Errorf("Clipboard was overwritten with %q", clip.value)
+	}
+}
+
+func TestClearUnreadable(t *testing.T) {
+	clip := &fakeClipboard{value: "123456", writable: true}
+	if cleared, err := clearIfUnchanged(clip, "123456"); cleared || err != nil {
+		t.Errorf("Unreadable clipboard should be left alone: %v", err)
+	}
+}
+
+func TestClearUnwritable(t *testing.T) {
+	clip := &fakeClipboard{value: "123456", readable: true}
+	if _, err := clearIfUnchanged(clip, "123456"); err == nil {
+		t.Error("Failed write should produce an error")
+	}
+}
+
+func TestCopyCode(t *testing.T) {
+	saved := stdout
+	defer func() { stdout = saved }()
+	var buf bytes.Buffer
+	stdout = &buf
+
+	// a one second period keeps the wait for the code to expire short
+	clip := &fakeClipboard{writable: true, readable: true}
+	start := time.Now()
+	if err := copyCode(clip, key{Secret: "MFRGGZDFMZTWQ2LK", Period: 1}, "gh", 0); err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(start) > 3*time.Second {
+		t.Errorf("Clipboard was cleared after %v", time.Since(start))
+	}
+	if len(clip.writes) != 2 || len(clip.writes[0]) != 6 || clip.writes[1] != "" {
+		t.Fatalf("Code was not copied then cleared: %q", clip.writes)
+	}
+	if !strings.HasPrefix(buf.String(), clip.writes[0]+" copied") {
+		t.Errorf("Unexpected output: %q", buf.String())
+	}
+}
+
+func TestCopyCodeChanged(t *testing.T) {
+	saved := stdout
+	defer func() { stdout = saved }()
+	stdout = &bytes.Buffer{}
+
+	// something copied while waiting must survive the clear
+	clip := &fakeClipboard{writable: true, readable: true}
+	if err := copyCode(&overwrittenClipboard{clip}, key{Secret: "MFRGGZDFMZTWQ2LK", Period: 1}, "gh", 0); err != nil {
+		t.Fatal(err)
+	}
+	if clip.value != "something else" {
+		t.Errorf("Clipboard was overwritten with %q", clip.value)
+	}
+}
+
+// overwrittenClipboard stands in for the user copying something else as
+// soon as the code is copied.
+type overwrittenClipboard struct {
+	*fakeClipboard
+}
+
+func (c *overwrittenClipboard) Write(s string) error {
+	if err := c.fakeClipboard.Write(s); err != nil {
+		return err
+	}
+	if s != "" {
+		c.value = "something else"
+	}
+	return nil
+}
+
+func TestOSC52(t *testing.T) {
+	var buf bytes.Buffer
+	if err := (osc52Clipboard{&buf}).Write("123456"); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "\033]52;c;MTIzNDU2\a" {
+		t.Errorf("Unexpected escape sequence: %q", buf.String())
+	}
+	if _, err := (osc52Clipboard{&buf}).Read(); err == nil {
+		t.Error("Terminal clipboard should not be readable")
+	}
+
+	buf.Reset()
+	if cleared, err := clearIfUnchanged(osc52Clipboard{&buf}, "123456"); !cleared || err != nil {
+		t.Errorf("Terminal clipboard was not cleared: %v", err)
+	}
+	if buf.String() != "\033]52;c;\a" {
+		t.Errorf("Unexpected escape sequence: %q", buf.String())
+	}
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// completeCommand is the hidden command the completion scripts call with the
+// words typed so far. It prints a candidate for the last word on each line.
+const completeCommand = "__complete"
+
+var completionScripts = map[string]string{
+	"bash": `# bash completion for 2fa
+_2fa() {
+	local IFS=$'\n'
+	COMPREPLY=($(2fa ` + completeCommand + ` "${COMP_WORDS[@]:1:COMP_CWORD}" 2>/dev/null))
+}
+complete -o default -F _2fa 2fa
+`,
+	"zsh": `#compdef 2fa
+# zsh completion for 2fa
+_2fa() {
+	local -a candidates
+	candidates=(${(f)"$(2fa ` + completeCommand + ` "${(@)words[2,CURRENT]}" 2>/dev/null)"})
+	if (( ${#candidates} )); then
+		compadd -a candidates
+	else
+		_files
+	fi
+}
+compdef _2fa 2fa
+`,
+	"fish": `# fish completion for 2fa
+complete -c 2fa -f -a '(2fa ` + completeCommand + ` (commandline -opc)[2..-1] (commandline -ct) 2>/dev/null)'
+`,
+}
+
+// completer is implemented by commands whose positional arguments can be
+// completed. It is given the positional arguments before the current word.
+type completer interface {
+	Complete(args []string) []string
+}
+
+type completionCommand struct{}
+
+func (c *completionCommand) Name() string {
+	return "completion"
+}
+
+func (c *completionCommand) FlagSet() *flag.FlagSet {
+	return newFlagSet(c.Name())
+}
+
+func (c *completionCommand) Run(args []string) error {
+	fs := c.FlagSet()
+	if err := fs.Parse(args); err != nil {
+		return errUsage("%v", err)
+	}
+	if fs.NArg() != 1 {
+		return errUsage("completion takes a shell")
+	}
+	script, ok := completionScripts[fs.Arg(0)]
+	if !ok {
+		return errUsage("unknown shell %q; use bash, fish or zsh", fs.Arg(0))
+	}
+	_, err := fmt.Fprint(stdout, script)
+	return err
+}
+
+func (c *completionCommand) Complete(args []string) []string {
+	if len(args) > 0 {
+		return nil
+	}
+	return []string{"bash", "fish", "zsh"}
+}
+
+func (c *completionCommand) Usage() {
+	usage := "    completion  generate a shell completion script"
+	fmt.Println(usage)
+}
+
+func (c *completionCommand) Help() {
+	help := "\n" + c.Name() + " usage:\n\n    2fa " + c.Name() + " bash|zsh|fish\n\n"
+	help += "    Prints a script completing commands, flags and key labels. For example:\n\n"
+	help += "        2fa completion bash > /etc/bash_completion.d/2fa\n"
+	help += "        2fa completion zsh > \"${fpath[1]}/_2fa\"\n"
+	help += "        2fa completion fish > ~/.config/fish/completions/2fa.fish\n"
+	fmt.Println(help)
+}
+
+func (c *calcCommand) Complete(args []string) []string {
+	if len(args) > 0 {
+		return nil
+	}
+	return completeLabels()
+}
+
+func (c *execCommand) Complete(args []string) []string {
+	if len(args) > 0 {
+		return nil
+	}
+	return completeLabels()
+}
+
+func (q *qrCommand) Complete(args []string) []string {
+	return completeLabels()
+}
+
+func completeLabels() []string {
+	cfg, err := loadCfg()
+	if err != nil {
+		return nil
+	}
+	return cfg.labels()
+}
+
+// completeFlagValue suggests values for the named flag.
+func completeFlagValue(name string) []string {
+	switch name {
+	case "output":
+		return outputFormats
+	case "sort":
+		return []string{"label", "issuer", "group"}
+	case "format":
+		return managerFormatNames()
+	case "profile":
+		home, err := homeDir()
+		if err != nil {
+			return nil
+		}
+		files, _ := ioutil.ReadDir(filepath.Join(xdgConfigHome(home), "2fa"))
+		profiles := []string{}
+		for _, f := range files {
+			if name := f.Name(); strings.HasSuffix(name, ".toml") && name != "config.toml" {
+				profiles = append(profiles, strings.TrimSuffix(name, ".toml"))
+			}
+		}
+		return profiles
+	case "group", "tag":
+		cfg, err := loadCfg()
+		if err != nil {
+			return nil
+		}
+		values := []string{}
+		for _, k := range cfg.Key {
+			if name == "tag" {
+				values = append(values, k.Tags...)
+				continue
+			}
+			// offer each folder above the group too
+			for g := k.Group; g != "" && g != "."; g = filepath.Dir(g) {
+				values = append(values, g)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface {
+		IsBoolFlag() bool
+	})
+	return ok && b.IsBoolFlag()
+}
+
+// skipFlags returns the index of the first positional word as fs would
+// parse them and, if the last word is a flag awaiting its value, its name.
+func skipFlags(fs *flag.FlagSet, words []string) (int, string) {
+	for i := 0; i < len(words); i++ {
+		w := words[i]
+		if w == "--" {
+			return i + 1, ""
+		}
+		if len(w) < 2 || w[0] != '-' {
+			return i, ""
+		}
+		name := strings.TrimLeft(w, "-")
+		if strings.Contains(name, "=") {
+			continue
+		}
+		if f := fs.Lookup(name); f != nil && !isBoolFlag(f) {
+			if i+1 == len(words) {
+				return len(words), name
+			}
+			i++
+		}
+	}
+	return len(words), ""
+}
+
+func flagNames(fs *flag.FlagSet) []string {
+	names := []string{}
+	fs.VisitAll(func(f *flag.Flag) {
+		names = append(names, "-"+f.Name)
+	})
+	return names
+}
+
+// complete returns the candidates for the last of the words typed after
+// 2fa, sorted and without duplicates.
+func complete(words []string) []string {
+	if len(words) == 0 {
+		words = []string{""}
+	}
+	prev, current := words[:len(words)-1], words[len(words)-1]
+	return filterPrefix(candidates(prev, current), current)
+}
+
+func candidates(prev []string, current string) []string {
+	global := newFlagSet("2fa")
+	globalFlags(global)
+	i, pending := skipFlags(global, prev)
+	if pending != "" {
+		return completeFlagValue(pending)
+	}
+	// honor -config and -profile when reading labels
+	global.SetOutput(ioutil.Discard)
+	global.Parse(prev[:i])
+
+	if i == len(prev) {
+		if strings.HasPrefix(current, "-") {
+			return flagNames(global)
+		}
+		names := []string{"help"}
+		for _, cmd := range commands {
+			names = append(names, cmd.Name())
+		}
+		return names
+	}
+
+	name, args := prev[i], prev[i+1:]
+	if name == "help" {
+		if len(args) > 0 {
+			return nil
+		}
+		return candidates(nil, current)
+	}
+	for _, cmd := range commands {
+		if cmd.Name() != name {
+			continue
+		}
+		fs := cmd.FlagSet()
+		j, pending := skipFlags(fs, args)
+		if pending != "" {
+			return completeFlagValue(pending)
+		}
+		if j == len(args) && strings.HasPrefix(current, "-") {
+			return flagNames(fs)
+		}
+		if c, ok := cmd.(completer); ok {
+			return c.Complete(args[j:])
+		}
+	}
+	return nil
+}
+
+func filterPrefix(candidates []string, prefix string) []string {
+	seen := map[string]bool{}
+	matches := []string{}
+	for _, c := range candidates {
+		if strings.HasPrefix(c, prefix) && !seen[c] {
+			seen[c] = true
+			matches = append(matches, c)
+		}
+	}
+	sort.Strings(matches)
+	return matches
+}
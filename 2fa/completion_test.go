@@ -0,0 +1,72 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestComplete(t *testing.T) {
+	home := setupCfgEnv(t)
+	dir := filepath.Join(home, ".config", "2fa")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	cfg := `
+[key.gh]
+secret = "MFRGGZDFMZTWQ2LK"
+group = "work/git"
+tags = ["code"]
+
+[key.gitlab]
+secret = "MFRGGZDFMZTWQ2LK"
+`
+	if err := os.WriteFile(filepath.Join(dir, "config.toml"), []byte(cfg), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "work.toml"), []byte(`[key.vpn]`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		words      string
+		candidates string
+	}{
+		{"", "calc completion doctor exec export help import init list qrcodes recovery serve-api ui watch"},
+		{"c", "calc completion"},
+		{"-", "-config -output -profile"},
+		{"-output ", "csv json plain"},
+		{"-profile ", "work"},
+		{"-profile work calc ", "vpn"},
+		{"calc ", "gh gitlab"},
+		{"calc gi", "gitlab"},
+		{"calc -watch g", "gh gitlab"},
+		{"calc -timeout ", ""},
+		{"calc gh ", ""},
+		{"calc -w", "-watch"},
+		{"calc -group ", "work work/git"},
+		{"list -tag ", "code"},
+		{"list -sort i", "issuer"},
+		{"export -format k", "keepassxc keepassxc-csv keepassxc-legacy"},
+		{"exec gh -- ", ""},
+		{"qrcodes gh ", "gh gitlab"},
+		{"help ", "calc completion doctor exec export help import init list qrcodes recovery serve-api ui watch"},
+		{"completion ", "bash fish zsh"},
+		{"nonsense ", ""},
+	}
+	for _, c := range cases {
+		got := strings.Join(complete(strings.Split(c.words, " ")), " ")
+		if got != c.candidates {
+			t.Errorf("complete(%q) = %q, expected %q", c.words, got, c.candidates)
+		}
+	}
+}
+
+func TestCompletionScripts(t *testing.T) {
+	for shell, script := range completionScripts {
+		if !strings.Contains(script, "2fa "+completeCommand) {
+			t.Errorf("%v script does not call %v", shell, completeCommand)
+		}
+	}
+}
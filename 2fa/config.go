@@ -1,24 +1,138 @@
 package main
 
 import (
+	"errors"
+	"fmt"
 	"github.com/BurntSushi/toml"
-	"log"
+	"io"
+	"os"
 	"os/user"
+	"path/filepath"
+	"strings"
 )
 
+// cfgFlag and profileFlag hold the global -config and -profile flags.
+var (
+	cfgFlag     string
+	profileFlag string
+)
+
+// getCfgPath returns the config path for use in messages.
 func getCfgPath() string {
-	usr, err := user.Current()
+	path, err := findCfgPath(cfgFlag, profileFlag)
 	if err != nil {
-		log.Fatal(err)
+		return "your config"
+	}
+	return path
+}
+
+// findCfgPath locates the config. An explicit path wins, then a named
+// profile, then $TWOFA_CONFIG, then ~/.2fa.toml if it exists, then the XDG
+// config directories.
+func findCfgPath(path, profile string) (string, error) {
+	if path != "" {
+		return path, nil
+	}
+
+	name := "config.toml"
+	if profile != "" {
+		if strings.ContainsAny(profile, `/\`) || strings.HasPrefix(profile, ".") {
+			return "", fmt.Errorf("invalid profile name %q", profile)
+		}
+		name = profile + ".toml"
+	} else if env := os.Getenv("TWOFA_CONFIG"); env != "" {
+		return env, nil
+	}
+
+	home, err := homeDir()
+	if err != nil {
+		return "", err
+	}
+
+	if profile == "" {
+		legacy := filepath.Join(home, ".2fa.toml")
+		if _, err := os.Stat(legacy); err == nil {
+			return legacy, nil
+		}
+	}
+
+	cfgHome := xdgConfigHome(home)
+	cfgDirs := os.Getenv("XDG_CONFIG_DIRS")
+	if cfgDirs == "" {
+		cfgDirs = "/etc/xdg"
+	}
+	for _, dir := range append([]string{cfgHome}, filepath.SplitList(cfgDirs)...) {
+		if !filepath.IsAbs(dir) {
+			continue
+		}
+		candidate := filepath.Join(dir, "2fa", name)
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate, nil
+		}
+	}
+	return filepath.Join(cfgHome, "2fa", name), nil
+}
+
+// xdgConfigHome returns $XDG_CONFIG_HOME, or its default of ~/.config.
+func xdgConfigHome(home string) string {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); filepath.IsAbs(dir) {
+		return dir
+	}
+	return filepath.Join(home, ".config")
+}
+
+// homeDir returns $HOME, falling back to the user database for environments
+// that don't set it.
+func homeDir() (string, error) {
+	if home := os.Getenv("HOME"); home != "" {
+		return home, nil
+	}
+	if usr, err := user.Current(); err == nil && usr.HomeDir != "" {
+		return usr.HomeDir, nil
 	}
-	return usr.HomeDir + "/.2fa.toml"
+	return "", errors.New("unable to find home directory; set $HOME or use -config")
 }
 
-func getCfg() *config {
+// loadCfg returns the config commands work with. Tests replace it to supply
+// a config from memory.
+var loadCfg = readCfg
+
+// readCfg reads the config from disk.
+func readCfg() (*config, error) {
 	var cfg config
-	path := getCfgPath()
+	path, err := findCfgPath(cfgFlag, profileFlag)
+	if err != nil {
+		return nil, configError{err}
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil, configError{fmt.Errorf("no config at %v; run 2fa init to create one", path)}
+	}
 	if _, err := toml.DecodeFile(path, &cfg); err != nil {
-		log.Fatal(err)
+		return nil, configError{fmt.Errorf("unable to read %v: %v", path, err)}
+	}
+	return &cfg, nil
+}
+
+// replaceFile writes a new file and renames it over path, so readers never
+// see a partial file. The file is readable only by its owner, whatever the
+// permissions of the one it replaces.
+func replaceFile(path string, write func(io.Writer) error) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
 	}
-	return &cfg
+	return os.Rename(f.Name(), path)
 }
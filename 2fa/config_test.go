@@ -0,0 +1,79 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func setupCfgEnv(t *testing.T) string {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("TWOFA_CONFIG", "")
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("XDG_CONFIG_DIRS", filepath.Join(home, "etc"))
+	return home
+}
+
+func TestFindCfgPathDefault(t *testing.T) {
+	home := setupCfgEnv(t)
+	path, err := findCfgPath("", "")
+	if err != nil || path != filepath.Join(home, ".config", "2fa", "config.toml") {
+		t.Errorf("Unexpected default path %v: %v", path, err)
+	}
+
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, "xdg"))
+	path, err = findCfgPath("", "")
+	if err != nil || path != filepath.Join(home, "xdg", "2fa", "config.toml") {
+		t.Errorf("XDG_CONFIG_HOME ignored: %v %v", path, err)
+	}
+}
+
+func TestFindCfgPathLegacy(t *testing.T) {
+	home := setupCfgEnv(t)
+	legacy := filepath.Join(home, ".2fa.toml")
+	if err := os.WriteFile(legacy, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if path, err := findCfgPath("", ""); err != nil || path != legacy {
+		t.Errorf("Existing ~/.2fa.toml not used: %v %v", path, err)
+	}
+}
+
+func TestFindCfgPathConfigDirs(t *testing.T) {
+	home := setupCfgEnv(t)
+	system := filepath.Join(home, "etc", "2fa", "config.toml")
+	if err := os.MkdirAll(filepath.Dir(system), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(system, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if path, err := findCfgPath("", ""); err != nil || path != system {
+		t.Errorf("XDG_CONFIG_DIRS not searched: %v %v", path, err)
+	}
+}
+
+func TestFindCfgPathPrecedence(t *testing.T) {
+	home := setupCfgEnv(t)
+	t.Setenv("TWOFA_CONFIG", "/env.toml")
+
+	if path, _ := findCfgPath("/flag.toml", "work"); path != "/flag.toml" {
+		t.Errorf("-config should win, got %v", path)
+	}
+	if path, _ := findCfgPath("", "work"); path != filepath.Join(home, ".config", "2fa", "work.toml") {
+		t.Errorf("-profile should beat $TWOFA_CONFIG, got %v", path)
+	}
+	if path, _ := findCfgPath("", ""); path != "/env.toml" {
+		t.Errorf("$TWOFA_CONFIG ignored, got %v", path)
+	}
+}
+
+func TestFindCfgPathBadProfile(t *testing.T) {
+	setupCfgEnv(t)
+	for _, profile := range []string{"../work", "a/b", ".hidden"} {
+		if _, err := findCfgPath("", profile); err == nil {
+			t.Errorf("Profile %q should be rejected", profile)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/tristanwietsma/otp"
+	"net"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// skewFraction is the fraction of the shortest period the clock may be off
+// by before doctor warns; codes near a rollover start failing beyond it.
+const skewFraction = 4
+
+// ntpTimeout bounds the wait for a reply from the time server.
+const ntpTimeout = 5 * time.Second
+
+type doctorCommand struct {
+	ntp string
+}
+
+func (c *doctorCommand) Name() string {
+	return "doctor"
+}
+
+func (c *doctorCommand) FlagSet() *flag.FlagSet {
+	fs := newFlagSet(c.Name())
+	fs.StringVar(&c.ntp, "ntp", "", "")
+	return fs
+}
+
+func (c *doctorCommand) Run(args []string) error {
+	fs := c.FlagSet()
+	if err := fs.Parse(args); err != nil {
+		return errUsage("%v", err)
+	}
+	if fs.NArg() != 0 {
+		return errUsage("doctor takes no arguments")
+	}
+
+	path, err := findCfgPath(cfgFlag, profileFlag)
+	if err != nil {
+		return configError{err}
+	}
+	cfg, err := loadCfg()
+	if err != nil {
+		return err
+	}
+
+	checks := checkPerms(path)
+	for _, label := range cfg.labels() {
+		checks = append(checks, checkKey(label, cfg.Key[label])...)
+	}
+	checks = append(checks, checkDuplicates(cfg)...)
+	if c.ntp != "" {
+		checks = append(checks, checkClock(cfg, c.ntp))
+	}
+
+	problems := 0
+	e := newEmitter()
+	for _, r := range checks {
+		if r.Level == "error" {
+			problems++
+		}
+		if structured() {
+			if err := e.emit(r); err != nil {
+				return err
+			}
+			continue
+		}
+		fmt.Fprintln(stdout, r)
+	}
+	if !structured() {
+		fmt.Fprintf(stdout, "checked %d keys in %v\n", len(cfg.Key), path)
+	}
+
+	if problems > 0 {
+		return fmt.Errorf("found %d problems", problems)
+	}
+	return nil
+}
+
+// checkPerms warns if the config at path is readable by anyone but its
+// owner.
+func checkPerms(path string) []checkRecord {
+	info, err := os.Stat(path)
+	if err != nil || runtime.GOOS == "windows" {
+		return nil
+	}
+	if info.Mode().Perm()&0077 != 0 {
+		return []checkRecord{{"permissions", "", "warning",
+			fmt.Sprintf("%v is accessible by other users; run chmod 600 %v", path, path)}}
+	}
+	return nil
+}
+
+// checkKey reports why a key can't produce codes, if it can't.
+func checkKey(label string, k key) []checkRecord {
+	checks := []checkRecord{}
+	if k.Period < 0 {
+		checks = append(checks, checkRecord{"key", label, "warning",
+			fmt.Sprintf("period is %d; using %d seconds instead", k.Period, k.period())})
+	}
+
+	tk := otp.Key{
+		Method:   "totp",
+		Label:    label,
+		Secret32: k.Secret,
+		Issuer:   k.Issuer,
+		Algo:     otp.Hashes[0],
+		Digits:   6,
+		Period:   int(k.period()),
+	}
+	var verr *otp.ValidationError
+	if errors.As(tk.Validate(), &verr) {
+		for _, f := range verr.Fields {
+			msg := f.Error()
+			if errors.Is(f, otp.ErrMissingSecret) || errors.Is(f, otp.ErrInvalidSecret) {
+				if problem := secretProblem(k.Secret); problem != "" {
+					msg = problem
+				}
+			}
+			checks = append(checks, checkRecord{"key", label, "error", msg})
+		}
+	}
+	return checks
+}
+
+// base32Alphabet holds the characters of a Base32 secret, less padding.
+const base32Alphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZ234567"
+
+// secretProblem describes what is wrong with a Base32 secret, or returns ""
+// if nothing specific is.
+func secretProblem(secret string) string {
+	switch {
+	case secret == "":
+		return "secret is missing"
+	case strings.ContainsAny(secret, " \t"):
+		return "secret contains spaces; remove them"
+	case strings.ToUpper(secret) != secret:
+		return "secret contains lowercase letters; Base32 secrets are uppercase"
+	case strings.Trim(secret, base32Alphabet+"=") != "":
+		bad := strings.Trim(secret, base32Alphabet+"=")
+		return fmt.Sprintf("secret contains %q; Base32 secrets only use A-Z and 2-7", bad[:1])
+	case len(secret)%8 != 0:
+		return fmt.Sprintf("secret is %d characters; pad it with = to a multiple of 8", len(secret))
+	}
+	return ""
+}
+
+// checkDuplicates warns about keys sharing a secret, which usually means a
+// key was added twice or copied by mistake.
+func checkDuplicates(cfg *config) []checkRecord {
+	bySecret := map[string][]string{}
+	secrets := []string{}
+	for _, label := range cfg.labels() {
+		s := strings.TrimRight(strings.ToUpper(strings.Join(strings.Fields(cfg.Key[label].Secret), "")), "=")
+		if s == "" {
+			continue
+		}
+		if len(bySecret[s]) == 0 {
+			secrets = append(secrets, s)
+		}
+		bySecret[s] = append(bySecret[s], label)
+	}
+
+	checks := []checkRecord{}
+	for _, s := range secrets {
+		if labels := bySecret[s]; len(labels) > 1 {
+			checks = append(checks, checkRecord{"duplicates", labels[0], "warning",
+				"keys share a secret: " + strings.Join(labels, ", ")})
+		}
+	}
+	return checks
+}
+
+// checkClock compares the local clock with the SNTP server and warns when
+// the difference is a large part of the shortest key period.
+func checkClock(cfg *config, server string) checkRecord {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "123")
+	}
+	offset, err := sntpOffset(server, ntpTimeout)
+	if err != nil {
+		return checkRecord{"clock", "", "warning", fmt.Sprintf("unable to query %v: %v", server, err)}
+	}
+
+	period := int64(30)
+	for _, k := range cfg.Key {
+		if k.period() < period {
+			period = k.period()
+		}
+	}
+
+	skew := offset
+	if skew < 0 {
+		skew = -skew
+	}
+
+	limit := time.Duration(period) * time.Second / skewFraction
+	direction := "behind"
+	if offset < 0 {
+		direction = "ahead of"
+	}
+	msg := fmt.Sprintf("clock is %v %v %v", skew.Round(time.Millisecond), direction, server)
+	if skew > limit {
+		return checkRecord{"clock", "", "warning", msg + fmt.Sprintf("; codes may be rejected beyond %v", limit)}
+	}
+	return checkRecord{"clock", "", "ok", msg}
+}
+
+func (c *doctorCommand) Usage() {
+	usage := "    doctor      check the config for problems"
+	fmt.Println(usage)
+}
+
+func (c *doctorCommand) Help() {
+	help := "\n" + c.Name() + " usage:\n\n    2fa " + c.Name() + " [-ntp server]\n\n"
+	help += "    Checks " + getCfgPath() + " for keys unable to produce codes, keys sharing\n"
+	help += "    a secret and permissions letting other users read it.\n\n"
+	help += "    -ntp        also compare the clock with an SNTP server, such as pool.ntp.org\n"
+	fmt.Println(help)
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var doctorCfg = &config{
+	Key: map[string]key{
+		"gh":      key{Issuer: "GitHub", Secret: "MFRGGZDFMZTWQ2LK"},
+		"gh-copy": key{Issuer: "GitHub", Secret: "mfrg gzdf mztw q2lk"},
+		"aws":     key{Issuer: "Amazon", Secret: "NAR5XTDD3EQU22YU", Period: -5},
+		"bank":    key{Issuer: "Bank", Secret: "NAR5XTDD3EQU22Y1"},
+		"short":   key{Issuer: "Short", Secret: "MFRGGZDFMZ"},
+		"empty":   key{Issuer: "Empty"},
+	},
+}
+
+// withCfgFile points the -config flag at a file with the given mode for the
+// duration of the test.
+func withCfgFile(t *testing.T, mode os.FileMode) string {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(path, nil, mode); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		t.Fatal(err)
+	}
+	saved := cfgFlag
+	t.Cleanup(func() { cfgFlag = saved })
+	cfgFlag = path
+	return path
+}
+
+func TestSecretProblem(t *testing.T) {
+	tests := []struct {
+		secret, problem string
+	}{
+		{"MFRGGZDFMZTWQ2LK", ""},
+		{"MFRGGZDFMZ======", ""},
+		{"", "missing"},
+		{"MFRG GZDF", "spaces"},
+		{"mfrggzdf", "lowercase"},
+		{"MFRGGZD1", `"1"`},
+		{"MFRGGZDFMZ", "multiple of 8"},
+	}
+
+	for _, test := range tests {
+		problem := secretProblem(test.secret)
+		if (test.problem == "") != (problem == "") || !strings.Contains(problem, test.problem) {
+			t.Errorf("Expected a problem mentioning %q for %q, got %q", test.problem, test.secret, problem)
+		}
+	}
+}
+
+func TestCheckKey(t *testing.T) {
+	for label, level := range map[string]string{"gh": "", "aws": "warning", "bank": "error", "short": "error", "empty": "error"} {
+		checks := checkKey(label, doctorCfg.Key[label])
+		got := ""
+		if len(checks) > 0 {
+			got = checks[0].Level
+		}
+		if got != level {
+			t.Errorf("Expected %q for %v, got %v", level, label, checks)
+		}
+	}
+}
+
+func TestCheckDuplicates(t *testing.T) {
+	checks := checkDuplicates(doctorCfg)
+	if len(checks) != 1 || checks[0].Message != "keys share a secret: gh, gh-copy" {
+		t.Errorf("Expected gh and gh-copy to be reported, got %v", checks)
+	}
+	if checks := checkDuplicates(cmdCfg.subset([]string{"gh", "aws"})); len(checks) != 0 {
+		t.Errorf("Expected no duplicates, got %v", checks)
+	}
+}
+
+func TestCheckPerms(t *testing.T) {
+	if checks := checkPerms(withCfgFile(t, 0600)); len(checks) != 0 {
+		t.Errorf("Expected 0600 to pass, got %v", checks)
+	}
+	if checks := checkPerms(withCfgFile(t, 0644)); len(checks) != 1 {
+		t.Errorf("Expected 0644 to be reported, got %v", checks)
+	}
+}
+
+func TestCheckClock(t *testing.T) {
+	cfg := cmdCfg.subset([]string{"gh"})
+	if r := checkClock(cfg, fakeSNTP(t, 0)); r.Level != "ok" {
+		t.Errorf("Expected an accurate clock to pass, got %v", r)
+	}
+	if r := checkClock(cfg, fakeSNTP(t, 10*time.Second)); r.Level != "warning" || !strings.Contains(r.Message, "behind") {
+		t.Errorf("Expected a slow clock to be reported, got %v", r)
+	}
+	if r := checkClock(cfg, fakeSNTP(t, -10*time.Second)); r.Level != "warning" || !strings.Contains(r.Message, "ahead of") {
+		t.Errorf("Expected a fast clock to be reported, got %v", r)
+	}
+}
+
+func TestDoctorCommand(t *testing.T) {
+	withCfgFile(t, 0600)
+	out, err := runCommand(cmdCfg.subset([]string{"gh", "aws"}), "plain", "doctor", "-ntp", fakeSNTP(t, 0))
+	if err != nil {
+		t.Fatalf("Expected no problems, got %v:\n%v", err, out)
+	}
+	if !strings.Contains(out, "ok       clock is") || !strings.Contains(out, "checked 2 keys") {
+		t.Errorf("Unexpected output:\n%v", out)
+	}
+
+	out, err = runCommand(doctorCfg, "json", "doctor")
+	if err == nil || exitStatus(err) != exitFailure {
+		t.Errorf("Expected problems to fail, got %v", err)
+	}
+	if n := strings.Count(out, `"level":"error"`); n != 4 {
+		t.Errorf("Expected 4 errors, got %v:\n%v", n, out)
+	}
+}
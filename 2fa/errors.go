@@ -0,0 +1,93 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Exit statuses, one for each kind of error a command can return.
+const (
+	exitFailure = 1 // anything not covered below
+	exitUsage   = 2 // bad flags or arguments
+	exitConfig  = 3 // the config is missing or unreadable
+	exitNoMatch = 4 // no key, or more than one, matched a label
+	exitBadKey  = 5 // a key is unable to produce a code
+)
+
+// usageError reports a command run with bad flags or arguments.
+type usageError struct {
+	msg string
+}
+
+func (e usageError) Error() string {
+	return e.msg
+}
+
+func errUsage(format string, a ...interface{}) error {
+	return usageError{fmt.Sprintf(format, a...)}
+}
+
+// configError reports a config that can't be found or read.
+type configError struct {
+	err error
+}
+
+func (e configError) Error() string {
+	return e.err.Error()
+}
+
+// matchError reports a label that matched no key, or several.
+type matchError struct {
+	msg string
+}
+
+func (e matchError) Error() string {
+	return e.msg
+}
+
+// keyError reports a key that is unable to produce a code.
+type keyError struct {
+	label string
+	err   error
+}
+
+func (e keyError) Error() string {
+	return fmt.Sprintf("unable to calculate code for %v; verify its secret in %v: %v", e.label, getCfgPath(), e.err)
+}
+
+// childError carries the exit status of a command run by exec, which 2fa
+// exits with in turn.
+type childError struct {
+	status int
+}
+
+func (e childError) Error() string {
+	return fmt.Sprintf("command exited with status %d", e.status)
+}
+
+// exitStatus maps an error returned by a command to the status 2fa exits
+// with.
+func exitStatus(err error) int {
+	var (
+		usage  usageError
+		cfg    configError
+		match  matchError
+		badKey keyError
+		child  childError
+	)
+	switch {
+	case err == nil:
+		return 0
+	case errors.As(err, &usage):
+		return exitUsage
+	case errors.As(err, &cfg):
+		return exitConfig
+	case errors.As(err, &match):
+		return exitNoMatch
+	case errors.As(err, &badKey):
+		return exitBadKey
+	case errors.As(err, &child):
+		return child.status
+	}
+	return exitFailure
+}
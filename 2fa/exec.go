@@ -0,0 +1,117 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+)
+
+// codePlaceholder is replaced with the code in the arguments of exec.
+const codePlaceholder = "{{code}}"
+
+type execCommand struct {
+	fresh int64
+}
+
+func (c *execCommand) Name() string {
+	return "exec"
+}
+
+func (c *execCommand) FlagSet() *flag.FlagSet {
+	fs := newFlagSet(c.Name())
+	fs.Int64Var(&c.fresh, "fresh", 5, "")
+	return fs
+}
+
+func (c *execCommand) Run(args []string) error {
+	fs := c.FlagSet()
+	if err := fs.Parse(args); err != nil {
+		return errUsage("%v", err)
+	}
+
+	rest := fs.Args()
+	if len(rest) > 1 && rest[1] == "--" {
+		rest = append(rest[:1], rest[2:]...)
+	}
+	if len(rest) < 2 {
+		return errUsage("exec takes a label and a command")
+	}
+
+	cfg, err := loadCfg()
+	if err != nil {
+		return err
+	}
+	label, err := resolve(cfg, rest[0])
+	if err != nil {
+		return err
+	}
+	code, _, err := freshCode(label, cfg.Key[label], c.fresh)
+	if err != nil {
+		return err
+	}
+
+	status, err := runWithCode(code, rest[1], rest[2:]...)
+	if err != nil {
+		return err
+	}
+	if status != 0 {
+		return childError{status}
+	}
+	return nil
+}
+
+func (c *execCommand) Usage() {
+	usage := "    exec        run a command with a one-time password"
+	fmt.Println(usage)
+}
+
+func (c *execCommand) Help() {
+	help := "\n" + c.Name() + " usage:\n\n    2fa " + c.Name() + " [-fresh seconds] label -- command [arguments]\n\n"
+	help += "    Runs the command with the key's code in $TWOFA_CODE and in place of any\n"
+	help += "    " + codePlaceholder + " in its arguments, then exits with the command's status.\n\n"
+	help += "    -fresh      wait for the next code if fewer seconds remain (default 5)\n\n"
+	help += "    Example:\n\n"
+	help += "        2fa exec aws -- aws sts get-session-token --token-code " + codePlaceholder + "\n"
+	fmt.Println(help)
+}
+
+// expandCode replaces the placeholder in each argument with the code.
+func expandCode(args []string, code string) []string {
+	expanded := make([]string, len(args))
+	for i, arg := range args {
+		expanded[i] = strings.Replace(arg, codePlaceholder, code, -1)
+	}
+	return expanded
+}
+
+// runWithCode runs the command with the code in its environment and
+// arguments, returning its exit status. Interrupts are left to the child,
+// which shares the terminal.
+func runWithCode(code, name string, args ...string) (int, error) {
+	cmd := exec.Command(name, expandCode(args, code)...)
+	cmd.Env = append(os.Environ(), "TWOFA_CODE="+code)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if status := exitErr.ExitCode(); status >= 0 {
+			return status, nil
+		}
+		// killed by a signal; report it the way shells do
+		if ws, ok := exitErr.Sys().(interface{ Signal() syscall.Signal }); ok {
+			return 128 + int(ws.Signal()), nil
+		}
+		return 1, nil
+	}
+	return 0, err
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestExpandCode(t *testing.T) {
+	args := expandCode([]string{"--token-code", "{{code}}", "pin{{code}}", "{{other}}"}, "123456")
+	if strings.Join(args, " ") != "--token-code 123456 pin123456 {{other}}" {
+		t.Errorf("Unexpected arguments: %v", args)
+	}
+}
+
+func TestRunWithCode(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no shell available")
+	}
+
+	status, err := runWithCode("123456", "sh", "-c", `test "$TWOFA_CODE" = 123456 && test "$0" = 123456`, "{{code}}")
+	if err != nil || status != 0 {
+		t.Errorf("Code not passed to the child: %v %v", status, err)
+	}
+
+	status, err = runWithCode("123456", "sh", "-c", "exit 3")
+	if err != nil || status != 3 {
+		t.Errorf("Expected exit status 3, got %v: %v", status, err)
+	}
+
+	status, err = runWithCode("123456", "sh", "-c", "kill -TERM $$")
+	if err != nil || status != 143 {
+		t.Errorf("Expected exit status 143, got %v: %v", status, err)
+	}
+
+	if _, err := runWithCode("123456", "2fa-no-such-command"); err == nil {
+		t.Error("Missing command should fail")
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+type exportCommand struct {
+	format string
+	group  string
+	tags   tagsFlag
+	force  bool
+}
+
+func (c *exportCommand) Name() string {
+	return "export"
+}
+
+func (c *exportCommand) FlagSet() *flag.FlagSet {
+	fs := newFlagSet(c.Name())
+	fs.StringVar(&c.format, "format", "", "")
+	fs.StringVar(&c.group, "group", "", "")
+	c.tags = nil
+	fs.Var(&c.tags, "tag", "")
+	fs.BoolVar(&c.force, "force", false, "")
+	return fs
+}
+
+func (c *exportCommand) Run(args []string) error {
+	fs := c.FlagSet()
+	if err := fs.Parse(args); err != nil {
+		return errUsage("%v", err)
+	}
+	if fs.NArg() > 1 {
+		return errUsage("export takes at most one file")
+	}
+	file := fs.Arg(0)
+	if file == "" && c.format == "" {
+		return errUsage("export to stdout needs a -format")
+	}
+	format, err := findManagerFormat(c.format, file)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := loadCfg()
+	if err != nil {
+		return err
+	}
+	labels := cfg.filter(c.group, c.tags)
+	if len(labels) == 0 {
+		return matchError{"no keys to export"}
+	}
+	keys := []managerKey{}
+	for _, label := range labels {
+		k, err := toOTP(label, cfg.Key[label])
+		if err != nil {
+			return err
+		}
+		keys = append(keys, managerKey{label: label, group: cfg.Key[label].Group, key: k})
+	}
+
+	if file == "" || file == "-" {
+		return format.write(stdout, keys)
+	}
+	return writeExport(file, c.force, keys, format.write)
+}
+
+// writeExport writes the keys to a new file, readable only by you as it
+// holds their secrets. An existing file is only replaced with force, and
+// then by renaming a new file over it, so it never keeps looser permissions.
+func writeExport(path string, force bool, keys []managerKey, write func(io.Writer, []managerKey) error) error {
+	var err error
+	if force {
+		err = replaceFile(path, func(w io.Writer) error { return write(w, keys) })
+	} else {
+		err = writeNewFile(path, func(w io.Writer) error { return write(w, keys) })
+	}
+	if os.IsExist(err) {
+		return errUsage("%v already exists; use -force to replace it", path)
+	}
+	if err != nil {
+		return err
+	}
+	if structured() {
+		return newEmitter().emit(pathRecord{path, true})
+	}
+	fmt.Fprintf(stdout, "exported %d keys to %v\n", len(keys), path)
+	return nil
+}
+
+// writeNewFile writes a file at path, which must not exist, readable only by
+// its owner. A partial file is removed if writing fails.
+func writeNewFile(path string, write func(io.Writer) error) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	err = write(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path)
+	}
+	return err
+}
+
+func (c *exportCommand) Usage() {
+	usage := "    export      write keys for a password manager to import"
+	fmt.Println(usage)
+}
+
+func (c *exportCommand) Help() {
+	help := "\n" + c.Name() + " usage:\n\n    2fa " + c.Name() + " [-format name] [-group name] [-tag name]... [-force] [file]\n\n"
+	help += "    Writes keys in a format a password manager imports, to a new file, which is\n"
+	help += "    readable only by you, or to stdout. The export holds the keys' secrets.\n\n"
+	help += "    -format     keepassxc (XML for keepassxc-cli import), keepassxc-legacy (XML\n"
+	help += "                with TOTP Seed and TOTP Settings), keepassxc-csv or bitwarden\n"
+	help += "                (JSON); guessed from the file's extension if not given\n"
+	help += "    -group      export only keys in this group\n"
+	help += "    -tag        export only keys with this tag; may be repeated\n"
+	help += "    -force      replace the file if it exists\n"
+	fmt.Println(help)
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+type importCommand struct {
+	format string
+	group  string
+}
+
+func (c *importCommand) Name() string {
+	return "import"
+}
+
+func (c *importCommand) FlagSet() *flag.FlagSet {
+	fs := newFlagSet(c.Name())
+	fs.StringVar(&c.format, "format", "", "")
+	fs.StringVar(&c.group, "group", "", "")
+	return fs
+}
+
+func (c *importCommand) Run(args []string) error {
+	fs := c.FlagSet()
+	if err := fs.Parse(args); err != nil {
+		return errUsage("%v", err)
+	}
+	if fs.NArg() != 1 {
+		return errUsage("import takes a file, or - for stdin")
+	}
+	file := fs.Arg(0)
+	format, err := findManagerFormat(c.format, file)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := loadCfg()
+	if err != nil {
+		return err
+	}
+	path, err := findCfgPath(cfgFlag, profileFlag)
+	if err != nil {
+		return configError{err}
+	}
+
+	var r io.Reader = stdin
+	if file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	found, err := format.read(r)
+	if err != nil {
+		return fmt.Errorf("unable to read %v as %v: %v", file, format.name, err)
+	}
+	if len(found) == 0 {
+		return matchError{fmt.Sprintf("no one-time passwords in %v", file)}
+	}
+
+	records := []importRecord{}
+	labels := []string{}
+	keys := map[string]key{}
+	for _, mk := range found {
+		label := strings.TrimSpace(mk.label)
+		r := importRecord{Label: label}
+		k, err := fromOTP(mk.key)
+		_, taken := cfg.Key[label]
+		_, imported := keys[label]
+		switch {
+		case mk.err != nil:
+			r.Reason = mk.err.Error()
+		case label == "":
+			r.Reason = "entry has no title"
+		case err != nil:
+			r.Reason = err.Error()
+		case taken || imported:
+			r.Reason = "label already in use"
+		}
+		if r.Reason != "" {
+			records = append(records, r)
+			continue
+		}
+
+		k.Group = mk.group
+		if c.group != "" {
+			k.Group = strings.Trim(c.group, "/")
+		}
+		r.Imported = true
+		labels = append(labels, label)
+		keys[label] = k
+		records = append(records, r)
+	}
+
+	if err := appendKeys(path, labels, keys); err != nil {
+		return err
+	}
+
+	e := newEmitter()
+	for _, r := range records {
+		if structured() {
+			if err := e.emit(r); err != nil {
+				return err
+			}
+			continue
+		}
+		fmt.Fprintln(stdout, r)
+	}
+	return nil
+}
+
+func (c *importCommand) Usage() {
+	usage := "    import      add keys from a password manager's export"
+	fmt.Println(usage)
+}
+
+func (c *importCommand) Help() {
+	help := "\n" + c.Name() + " usage:\n\n    2fa " + c.Name() + " [-format name] [-group name] file\n\n"
+	help += "    Adds the one-time passwords in a password manager's export to the end of\n"
+	help += "    " + getCfgPath() + ", leaving the rest of it as it was. Entries are labeled\n"
+	help += "    by their title and filed in their folder. Entries whose label is taken, or\n"
+	help += "    whose keys 2fa can't use, are skipped and reported, and KeePassXC's Recycle\n"
+	help += "    Bin is left out. Give - to read stdin.\n\n"
+	help += "    -format     keepassxc (XML), keepassxc-legacy (XML with TOTP Seed and TOTP\n"
+	help += "                Settings), keepassxc-csv or bitwarden (JSON); guessed from the\n"
+	help += "                file's extension if not given\n"
+	help += "    -group      file every imported key in this group instead\n"
+	fmt.Println(help)
+}
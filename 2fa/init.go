@@ -1,40 +1,81 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
 )
 
-type initCommand struct{}
-
-func (c initCommand) Name() string {
-	return "init"
-}
-
-func (c initCommand) Run(args []string) bool {
-	path := getCfgPath()
-	if _, err := os.Open(path); err != nil {
-		f, _ := os.Create(path)
-		f.WriteString(
-			`# 2fa configuration
+// cfgTemplate is the content of a new config.
+const cfgTemplate = `# 2fa configuration
 #
 # Example:
 #
 # [key.label]
 # issuer = "The Issuer"
 # secret = <Base32 encoded secret key>
-`)
+`
+
+type initCommand struct{}
+
+func (c *initCommand) Name() string {
+	return "init"
+}
+
+func (c *initCommand) FlagSet() *flag.FlagSet {
+	return newFlagSet(c.Name())
+}
+
+func (c *initCommand) Run(args []string) error {
+	fs := c.FlagSet()
+	if err := fs.Parse(args); err != nil {
+		return errUsage("%v", err)
+	}
+	if fs.NArg() != 0 {
+		return errUsage("init takes no arguments")
+	}
+
+	path, err := findCfgPath(cfgFlag, profileFlag)
+	if err != nil {
+		return configError{err}
+	}
+	created := false
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			return configError{fmt.Errorf("unable to create %v: %v", filepath.Dir(path), err)}
+		}
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err != nil {
+			return configError{fmt.Errorf("unable to create %v: %v", path, err)}
+		}
+		_, err = f.WriteString(cfgTemplate)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			// an empty config would pass for a created one next time
+			os.Remove(path)
+			return configError{fmt.Errorf("unable to write %v: %v", path, err)}
+		}
+		created = true
+	}
+
+	if structured() {
+		return newEmitter().emit(pathRecord{path, created})
 	}
-	return true
+	return nil
 }
 
-func (c initCommand) Usage() {
+func (c *initCommand) Usage() {
 	usage := "    init        create the user config"
 	fmt.Println(usage)
 }
 
-func (c initCommand) Help() {
+func (c *initCommand) Help() {
 	help := "\n" + c.Name() + " usage:\n\n    2fa " + c.Name() + "\n\n"
 	help += "    Creates a configuration file at " + getCfgPath() + " if one does not already exist.\n"
+	help += "    The file is only readable by you. Use the global -config or -profile flags\n"
+	help += "    to create it elsewhere.\n"
 	fmt.Println(help)
 }
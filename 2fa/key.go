@@ -1,10 +1,120 @@
 package main
 
+import (
+	"fmt"
+	"github.com/tristanwietsma/otp"
+	"sort"
+	"strings"
+	"time"
+)
+
 type config struct {
 	Key map[string]key
 }
 
+// labels returns the key labels in sorted order.
+func (c config) labels() []string {
+	labels := []string{}
+	for label := range c.Key {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	return labels
+}
+
+// filter returns the sorted labels of the keys in the group, or a folder
+// beneath it, that carry all of the tags. An empty group matches every key.
+func (c config) filter(group string, tags []string) []string {
+	labels := []string{}
+	for _, label := range c.labels() {
+		if c.Key[label].inGroup(group) && c.Key[label].hasTags(tags) {
+			labels = append(labels, label)
+		}
+	}
+	return labels
+}
+
+// subset returns a config holding only the labeled keys.
+func (c config) subset(labels []string) *config {
+	sub := config{Key: map[string]key{}}
+	for _, label := range labels {
+		sub.Key[label] = c.Key[label]
+	}
+	return &sub
+}
+
+// sortLabels orders labels by label, issuer or group; ties keep label order.
+func (c config) sortLabels(labels []string, by string) error {
+	field := map[string]func(k key) string{
+		"issuer": func(k key) string { return strings.ToLower(k.Issuer) },
+		"group":  func(k key) string { return k.Group },
+	}
+	sort.Strings(labels)
+	if by == "label" {
+		return nil
+	}
+	f, ok := field[by]
+	if !ok {
+		return fmt.Errorf("unable to sort by %q; use label, issuer or group", by)
+	}
+	sort.SliceStable(labels, func(i, j int) bool {
+		return f(c.Key[labels[i]]) < f(c.Key[labels[j]])
+	})
+	return nil
+}
+
 type key struct {
-	Secret string
-	Issuer string
+	Secret string   `toml:"secret"`
+	Issuer string   `toml:"issuer,omitempty"`
+	Period int      `toml:"period,omitzero"`
+	Group  string   `toml:"group,omitempty"` // Slash-separated folder, such as "work/aws".
+	Tags   []string `toml:"tags,omitempty"`  // Free-form tags, such as "prod".
+}
+
+// inGroup reports whether the key is in the group or a folder beneath it.
+func (k key) inGroup(group string) bool {
+	group = strings.Trim(group, "/")
+	return group == "" || k.Group == group || strings.HasPrefix(k.Group, group+"/")
+}
+
+// hasTags reports whether the key carries all of the tags.
+func (k key) hasTags(tags []string) bool {
+	for _, tag := range tags {
+		if !stringInSlice(tag, k.Tags) {
+			return false
+		}
+	}
+	return true
+}
+
+// period returns the key's period in seconds, defaulting to 30.
+func (k key) period() int64 {
+	if k.Period > 0 {
+		return int64(k.Period)
+	}
+	return 30
+}
+
+// algorithm returns the name of the key's hash algorithm. Like Google
+// Authenticator, 2fa always uses SHA1.
+func (k key) algorithm() string {
+	return "SHA1"
+}
+
+// expires returns the time the key's current code expires.
+func (k key) expires() time.Time {
+	iv, _ := otp.GetInterval(k.period())
+	return time.Unix((iv+1)*k.period(), 0)
+}
+
+// tagsFlag collects the values of a repeatable -tag flag.
+type tagsFlag []string
+
+func (t *tagsFlag) String() string {
+	return strings.Join(*t, ",")
+}
+
+func (t *tagsFlag) Set(tag string) error {
+	*t = append(*t, tag)
+	return nil
 }
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+var groupCfg = &config{
+	Key: map[string]key{
+		"ecr":     key{Issuer: "Amazon", Group: "work/aws", Tags: []string{"prod"}},
+		"console": key{Issuer: "Amazon", Group: "work/aws", Tags: []string{"prod", "admin"}},
+		"sandbox": key{Issuer: "Amazon", Group: "work/aws-sandbox"},
+		"gh":      key{Issuer: "GitHub", Group: "work"},
+		"bank":    key{Issuer: "Bank"},
+	},
+}
+
+func TestFilter(t *testing.T) {
+	cases := []struct {
+		group  string
+		tags   []string
+		labels string
+	}{
+		{"", nil, "bank,console,ecr,gh,sandbox"},
+		{"work", nil, "console,ecr,gh,sandbox"},
+		{"work/aws", nil, "console,ecr"},
+		{"/work/aws/", nil, "console,ecr"},
+		{"", []string{"prod"}, "console,ecr"},
+		{"", []string{"prod", "admin"}, "console"},
+		{"work/aws-sandbox", []string{"prod"}, ""},
+	}
+	for _, c := range cases {
+		if labels := strings.Join(groupCfg.filter(c.group, c.tags), ","); labels != c.labels {
+			t.Errorf("filter(%q, %v) = %q, expected %q", c.group, c.tags, labels, c.labels)
+		}
+	}
+}
+
+func TestSortLabels(t *testing.T) {
+	labels := groupCfg.labels()
+	if err := groupCfg.sortLabels(labels, "issuer"); err != nil {
+		t.Fatal(err)
+	}
+	if s := strings.Join(labels, ","); s != "console,ecr,sandbox,bank,gh" {
+		t.Errorf("Unexpected issuer order: %v", s)
+	}
+
+	if err := groupCfg.sortLabels(labels, "group"); err != nil {
+		t.Fatal(err)
+	}
+	if s := strings.Join(labels, ","); s != "bank,gh,console,ecr,sandbox" {
+		t.Errorf("Unexpected group order: %v", s)
+	}
+
+	if err := groupCfg.sortLabels(labels, "secret"); err == nil {
+		t.Error("Sorting by an unknown field should fail")
+	}
+}
@@ -1,24 +1,66 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"strings"
 )
 
-type listCommand struct{}
+type listCommand struct {
+	group string
+	by    string
+	tags  tagsFlag
+}
 
-func (c listCommand) Name() string {
+func (c *listCommand) Name() string {
 	return "list"
 }
 
-func (c listCommand) Run(args []string) bool {
-	cfg := getCfg()
-	fmt.Println("Label\tIssuer")
+func (c *listCommand) FlagSet() *flag.FlagSet {
+	fs := newFlagSet(c.Name())
+	fs.StringVar(&c.group, "group", "", "")
+	fs.StringVar(&c.by, "sort", "label", "")
+	c.tags = nil
+	fs.Var(&c.tags, "tag", "")
+	return fs
+}
+
+func (c *listCommand) Run(args []string) error {
+	fs := c.FlagSet()
+	if err := fs.Parse(args); err != nil {
+		return errUsage("%v", err)
+	}
+	if fs.NArg() != 0 {
+		return errUsage("list takes no arguments")
+	}
+
+	cfg, err := loadCfg()
+	if err != nil {
+		return err
+	}
+	labels := cfg.filter(c.group, c.tags)
+	if err := cfg.sortLabels(labels, c.by); err != nil {
+		return errUsage("%v", err)
+	}
+
+	if structured() {
+		e := newEmitter()
+		for _, label := range labels {
+			if err := e.emit(newKeyRecord(label, cfg.Key[label])); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	fmt.Fprintln(stdout, "Label\tIssuer\tGroup\tTags")
 
 	output := ""
 	line := ""
 	n := 0
-	for label, k := range cfg.Key {
-		line = fmt.Sprintf("%v\t%v\n", label, k.Issuer)
+	for _, label := range labels {
+		k := cfg.Key[label]
+		line = fmt.Sprintf("%v\t%v\t%v\t%v\n", label, k.Issuer, k.Group, strings.Join(k.Tags, ","))
 		m := len(line)
 		if m > n {
 			n = m
@@ -30,19 +72,22 @@ func (c listCommand) Run(args []string) bool {
 	for len(dash) < n+4 {
 		dash += "-"
 	}
-	fmt.Println(dash)
+	fmt.Fprintln(stdout, dash)
 
-	fmt.Print(output)
-	return true
+	fmt.Fprint(stdout, output)
+	return nil
 }
 
-func (c listCommand) Usage() {
+func (c *listCommand) Usage() {
 	usage := "    list        list keys"
 	fmt.Println(usage)
 }
 
-func (c listCommand) Help() {
-	help := "\n" + c.Name() + " usage:\n\n    totp " + c.Name() + "\n\n"
-	help += "    Lists all keys stored in " + getCfgPath() + ".\n"
+func (c *listCommand) Help() {
+	help := "\n" + c.Name() + " usage:\n\n    totp " + c.Name() + " [-group group] [-tag tag]... [-sort label|issuer|group]\n\n"
+	help += "    Lists all keys stored in " + getCfgPath() + ".\n\n"
+	help += "    -group      only list keys in the group or a folder beneath it\n"
+	help += "    -tag        only list keys with the tag; may be repeated\n"
+	help += "    -sort       order keys by label (the default), issuer or group\n"
 	fmt.Println(help)
 }
@@ -2,49 +2,90 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
+	"io/ioutil"
+	"os"
 )
 
 type command interface {
 	Name() string
-	Run([]string) bool
+	FlagSet() *flag.FlagSet
+	Run([]string) error
 	Usage()
 	Help()
 }
 
+// newFlagSet returns an empty flag set for a command. Commands describe
+// their flags in Help, so parse errors are reported without the defaults.
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {}
+	fs.SetOutput(ioutil.Discard)
+	return fs
+}
+
 var commands = []command{
 	&calcCommand{},
+	&watchCommand{},
+	&uiCommand{},
+	&execCommand{},
 	&listCommand{},
 	&initCommand{},
+	&importCommand{},
+	&exportCommand{},
+	&doctorCommand{},
+	&recoveryCommand{},
 	&qrCommand{},
+	&apiCommand{},
+	&completionCommand{},
 }
 
 func usage() {
-	fmt.Println(
+	fmt.Print(
 		`2fa is a time-based, one-time password generator.
 
 Usage:
 
-        2fa command [arguments]
+        2fa [-config path | -profile name] [-output plain|json|csv] command [arguments]
 
 The commands are:
+
 `)
 	for _, c := range commands {
 		c.Usage()
 	}
 
-	fmt.Println(
+	fmt.Print(
 		`
 Use "2fa help [command]" for more information about a command.
+
+The config is read from the -config path, the named -profile, $TWOFA_CONFIG,
+~/.2fa.toml if it exists, or $XDG_CONFIG_HOME/2fa/config.toml, in that order.
+Profiles are stored alongside it as $XDG_CONFIG_HOME/2fa/<name>.toml.
+
 `)
 }
 
+// globalFlags defines the flags accepted before the command.
+func globalFlags(fs *flag.FlagSet) {
+	fs.StringVar(&output, "output", "plain", "")
+	fs.StringVar(&cfgFlag, "config", "", "")
+	fs.StringVar(&profileFlag, "profile", "", "")
+}
+
 func main() {
 
 	flag.Usage = usage
+	globalFlags(flag.CommandLine)
 	flag.Parse()
 
+	if !stringInSlice(output, outputFormats) {
+		fmt.Fprintf(os.Stderr, "2fa: unknown output format %q; use plain, json or csv\n", output)
+		os.Exit(exitUsage)
+	}
+
 	if flag.NArg() < 1 {
 		usage()
 		return
@@ -52,30 +93,56 @@ func main() {
 
 	args := flag.Args()
 
+	if args[0] == completeCommand {
+		for _, candidate := range complete(args[1:]) {
+			fmt.Println(candidate)
+		}
+		return
+	}
+
 	// search commands
-	for _, cmd := range commands {
-		if cmd.Name() == args[0] {
-			if cmd.Run(args[1:]) {
-				return
-			}
+	if cmd := findCommand(args[0]); cmd != nil {
+		err := cmd.Run(args[1:])
+		if err != nil && !errors.As(err, &childError{}) {
+			fmt.Fprintf(os.Stderr, "2fa: %v\n", err)
+		}
+		if errors.As(err, &usageError{}) {
 			cmd.Help()
-			return
 		}
+		os.Exit(exitStatus(err))
 	}
 
 	// help
 	if args[0] == "help" {
 		if flag.NArg() != 2 {
-			fmt.Println("\nhelp usage:\n\n    2fa help [command]\n")
+			fmt.Print("\nhelp usage:\n\n    2fa help [command]\n\n")
 			return
 		}
-		for _, cmd := range commands {
-			if args[1] == cmd.Name() {
-				cmd.Help()
-				return
-			}
+		if cmd := findCommand(args[1]); cmd != nil {
+			cmd.Help()
+			return
 		}
 	}
 
 	usage()
+	os.Exit(exitUsage)
+}
+
+// findCommand returns the named command, or nil if there is none.
+func findCommand(name string) command {
+	for _, cmd := range commands {
+		if cmd.Name() == name {
+			return cmd
+		}
+	}
+	return nil
+}
+
+func stringInSlice(a string, list []string) bool {
+	for _, b := range list {
+		if b == a {
+			return true
+		}
+	}
+	return false
 }
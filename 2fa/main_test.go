@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var cmdCfg = &config{
+	Key: map[string]key{
+		"gh":     key{Issuer: "GitHub", Secret: "MFRGGZDFMZTWQ2LK", Group: "work", Tags: []string{"dev"}},
+		"aws":    key{Issuer: "Amazon", Secret: "NAR5XTDD3EQU22YU", Group: "work/aws"},
+		"bank":   key{Issuer: "Bank", Secret: "MFRGGZDFMZTWQ2LK", Period: 60},
+		"broken": key{Issuer: "Broken", Secret: "abc123", Group: "bad"},
+	},
+}
+
+// runCommand runs the named command against cfg with the output format,
+// returning what it wrote and the error it returned.
+func runCommand(cfg *config, format string, args ...string) (string, error) {
+	savedLoad, savedOut, savedFormat := loadCfg, stdout, output
+	defer func() { loadCfg, stdout, output = savedLoad, savedOut, savedFormat }()
+
+	var buf bytes.Buffer
+	loadCfg = func() (*config, error) { return cfg, nil }
+	stdout = &buf
+	output = format
+
+	cmd := findCommand(args[0])
+	if cmd == nil {
+		return "", errUsage("unknown command %q", args[0])
+	}
+	err := cmd.Run(args[1:])
+	return buf.String(), err
+}
+
+func TestCalcCommand(t *testing.T) {
+	out, err := runCommand(cmdCfg, "plain", "calc", "gh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "seconds)") || len(strings.Fields(out)[0]) != 6 {
+		t.Errorf("Unexpected output %q", out)
+	}
+
+	out, err = runCommand(cmdCfg, "json", "calc", "-group", "work")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 records, got %q", out)
+	}
+	var r keyRecord
+	if err := json.Unmarshal([]byte(lines[0]), &r); err != nil {
+		t.Fatal(err)
+	}
+	if r.Label != "aws" || len(r.Code) != 6 || r.ExpiresAt == nil {
+		t.Errorf("Unexpected record %+v", r)
+	}
+}
+
+func TestCommandErrors(t *testing.T) {
+	tests := []struct {
+		args   []string
+		status int
+	}{
+		{[]string{"calc"}, exitUsage},
+		{[]string{"calc", "-bogus", "gh"}, exitUsage},
+		{[]string{"calc", "-fresh", "soon", "gh"}, exitUsage},
+		{[]string{"calc", "gh", "aws"}, exitUsage},
+		{[]string{"calc", "zzzzzz"}, exitNoMatch},
+		{[]string{"calc", "-tag", "nope"}, exitNoMatch},
+		{[]string{"calc", "broken"}, exitBadKey},
+		{[]string{"calc", "-group", "bad"}, exitBadKey},
+		{[]string{"list", "extra"}, exitUsage},
+		{[]string{"list", "-sort", "secret"}, exitUsage},
+		{[]string{"watch", "gh"}, exitUsage},
+		{[]string{"watch", "-group", "nope"}, exitNoMatch},
+		{[]string{"exec", "gh"}, exitUsage},
+		{[]string{"exec", "zzzzzz", "--", "true"}, exitNoMatch},
+		{[]string{"qrcodes", "-tag", "nope"}, exitNoMatch},
+		{[]string{"qrcodes", "zzzzzz"}, exitNoMatch},
+		{[]string{"serve-api", "extra"}, exitUsage},
+		{[]string{"completion"}, exitUsage},
+		{[]string{"completion", "tcsh"}, exitUsage},
+		{[]string{"init", "extra"}, exitUsage},
+		{[]string{"ui", "extra"}, exitUsage},
+	}
+
+	for _, test := range tests {
+		_, err := runCommand(cmdCfg, "plain", test.args...)
+		if status := exitStatus(err); status != test.status {
+			t.Errorf("Expected status %v for %q, got %v (%v)", test.status, test.args, status, err)
+		}
+	}
+}
+
+func TestCommandConfigError(t *testing.T) {
+	saved := loadCfg
+	defer func() { loadCfg = saved }()
+	loadCfg = func() (*config, error) { return nil, configError{errors.New("no config")} }
+
+	for _, args := range [][]string{{"calc", "gh"}, {"list"}, {"exec", "gh", "true"}, {"qrcodes"}, {"serve-api"}} {
+		if err := findCommand(args[0]).Run(args[1:]); exitStatus(err) != exitConfig {
+			t.Errorf("Expected config error for %q, got %v", args, err)
+		}
+	}
+}
+
+func TestListCommand(t *testing.T) {
+	out, err := runCommand(cmdCfg, "plain", "list", "-sort", "issuer", "-group", "work")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 4 || !strings.HasPrefix(lines[2], "aws\t") || !strings.HasPrefix(lines[3], "gh\t") {
+		t.Errorf("Unexpected listing:\n%v", out)
+	}
+
+	out, err = runCommand(cmdCfg, "csv", "list", "-tag", "dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "label,issuer,code,expires_at,period,algorithm,group,tags\ngh,GitHub,,,30,SHA1,work,dev\n" {
+		t.Errorf("Unexpected csv %q", out)
+	}
+}
+
+func TestWatchCommand(t *testing.T) {
+	out, err := runCommand(cmdCfg, "json", "watch", "-timeout", "10ms", "-group", "work")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(out, "\n"); n != 2 {
+		t.Errorf("Expected a record per key, got %q", out)
+	}
+}
+
+func TestExecCommand(t *testing.T) {
+	_, err := runCommand(cmdCfg, "plain", "exec", "-fresh", "0", "gh", "--", "sh", "-c", `test ${#TWOFA_CODE} = 6`)
+	if err != nil {
+		t.Errorf("Expected success, got %v", err)
+	}
+
+	_, err = runCommand(cmdCfg, "plain", "exec", "-fresh", "0", "gh", "--", "sh", "-c", "exit 7")
+	if exitStatus(err) != 7 {
+		t.Errorf("Expected the child's status, got %v", err)
+	}
+}
+
+func TestInitCommand(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sub", "config.toml")
+	saved := cfgFlag
+	defer func() { cfgFlag = saved }()
+	cfgFlag = path
+
+	out, err := runCommand(cmdCfg, "json", "init")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, `"created":true`) {
+		t.Errorf("Unexpected output %q", out)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected mode 0600, got %v", info.Mode().Perm())
+	}
+
+	out, err = runCommand(cmdCfg, "json", "init")
+	if err != nil || !strings.Contains(out, `"created":false`) {
+		t.Errorf("Expected existing config to be kept, got %q (%v)", out, err)
+	}
+}
+
+func TestQrCodesCommand(t *testing.T) {
+	out, err := runCommand(cmdCfg, "json", "qrcodes", "-addr", "127.0.0.1:0", "-timeout", "10ms", "gh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, `"url":"http://127.0.0.1:`) {
+		t.Errorf("Unexpected output %q", out)
+	}
+}
+
+func TestCompletionCommand(t *testing.T) {
+	out, err := runCommand(cmdCfg, "plain", "completion", "bash")
+	if err != nil || out != completionScripts["bash"] {
+		t.Errorf("Expected the bash script, got %v", err)
+	}
+}
+
+func TestExitStatus(t *testing.T) {
+	tests := []struct {
+		err    error
+		status int
+	}{
+		{nil, 0},
+		{errors.New("boom"), exitFailure},
+		{errUsage("bad"), exitUsage},
+		{configError{errors.New("missing")}, exitConfig},
+		{matchError{"none"}, exitNoMatch},
+		{keyError{"gh", errors.New("bad secret")}, exitBadKey},
+		{childError{42}, 42},
+	}
+
+	for _, test := range tests {
+		if status := exitStatus(test.err); status != test.status {
+			t.Errorf("Expected %v for %v, got %v", test.status, test.err, status)
+		}
+	}
+}
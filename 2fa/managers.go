@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"github.com/tristanwietsma/otp"
+	"github.com/tristanwietsma/otp/bitwarden"
+	"github.com/tristanwietsma/otp/internal/otpauth"
+	"github.com/tristanwietsma/otp/keepassxc"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// managerKey is a key in a password manager's export.
+type managerKey struct {
+	label string // Title or name of the entry.
+	group string // Slash-separated folder of the entry.
+	user  string // Username of the entry.
+	key   otp.Key
+	err   error // Why the key couldn't be read, if it couldn't.
+}
+
+// managerFormat reads and writes a password manager's export.
+type managerFormat struct {
+	name  string
+	ext   string // Extension of files in the format, for guessing it.
+	read  func(r io.Reader) ([]managerKey, error)
+	write func(w io.Writer, keys []managerKey) error
+}
+
+var managerFormats = []managerFormat{
+	{"keepassxc", ".xml", readKeePassXC(keepassxc.ReadXML), writeKeePassXC(false)},
+	{"keepassxc-csv", ".csv", readKeePassXC(keepassxc.ReadCSV), writeKeePassXCCSV},
+	{"keepassxc-legacy", "", readKeePassXC(keepassxc.ReadXML), writeKeePassXC(true)},
+	{"bitwarden", ".json", readBitwarden, writeBitwarden},
+}
+
+// managerFormatNames returns the names of the formats, for completion.
+func managerFormatNames() []string {
+	names := []string{}
+	for _, f := range managerFormats {
+		names = append(names, f.name)
+	}
+	return names
+}
+
+// findManagerFormat returns the named format or, without a name, the one
+// the file's extension suggests.
+func findManagerFormat(name, path string) (managerFormat, error) {
+	for _, f := range managerFormats {
+		if f.name == name || name == "" && f.ext != "" && strings.EqualFold(filepath.Ext(path), f.ext) {
+			return f, nil
+		}
+	}
+	if name == "" {
+		return managerFormat{}, errUsage("unable to tell the format of %q; use -format %v", path, strings.Join(managerFormatNames(), "|"))
+	}
+	return managerFormat{}, errUsage("unknown format %q; use %v", name, strings.Join(managerFormatNames(), ", "))
+}
+
+func readKeePassXC(read func(io.Reader) ([]keepassxc.Entry, error)) func(io.Reader) ([]managerKey, error) {
+	return func(r io.Reader) ([]managerKey, error) {
+		entries, err := read(r)
+		if err != nil {
+			return nil, err
+		}
+		keys := []managerKey{}
+		for _, e := range entries {
+			keys = append(keys, managerKey{e.Title, e.Group, e.Username, e.Key, e.Err})
+		}
+		return keys, nil
+	}
+}
+
+func keePassXCEntries(keys []managerKey) []keepassxc.Entry {
+	entries := []keepassxc.Entry{}
+	for _, k := range keys {
+		entries = append(entries, keepassxc.Entry{Group: k.group, Title: k.label, Username: k.user, Key: k.key})
+	}
+	return entries
+}
+
+func writeKeePassXC(legacy bool) func(io.Writer, []managerKey) error {
+	return func(w io.Writer, keys []managerKey) error {
+		return keepassxc.WriteXML(w, keePassXCEntries(keys), legacy)
+	}
+}
+
+func writeKeePassXCCSV(w io.Writer, keys []managerKey) error {
+	return keepassxc.WriteCSV(w, keePassXCEntries(keys))
+}
+
+func readBitwarden(r io.Reader) ([]managerKey, error) {
+	items, err := bitwarden.ReadJSON(r)
+	if err != nil {
+		return nil, err
+	}
+	keys := []managerKey{}
+	for _, it := range items {
+		keys = append(keys, managerKey{it.Name, it.Folder, it.Username, it.Key, it.Err})
+	}
+	return keys, nil
+}
+
+func writeBitwarden(w io.Writer, keys []managerKey) error {
+	items := []bitwarden.Item{}
+	for _, k := range keys {
+		items = append(items, bitwarden.Item{Folder: k.group, Name: k.label, Username: k.user, Key: k.key})
+	}
+	return bitwarden.WriteJSON(w, items)
+}
+
+// fromOTP returns the config key for a key read from an export, or why 2fa
+// can't use it.
+func fromOTP(k otp.Key) (key, error) {
+	if k.Method != "totp" || k.Digits != 6 || otpauth.Algorithm(k.Algo) != "SHA1" {
+		return key{}, fmt.Errorf("2fa only supports 6-digit SHA1 totp keys")
+	}
+	period := k.Period
+	if period == 30 {
+		period = 0
+	}
+	return key{Secret: k.Secret32, Issuer: k.Issuer, Period: period}, nil
+}
+
+// toOTP returns the config key as an otp.Key.
+func toOTP(label string, k key) (otp.Key, error) {
+	ok := otp.Key{
+		Method:   "totp",
+		Label:    label,
+		Secret32: k.Secret,
+		Issuer:   k.Issuer,
+		Algo:     sha1.New,
+		Digits:   6,
+		Period:   int(k.period()),
+	}
+	if err := ok.Validate(); err != nil {
+		return ok, keyError{label, err}
+	}
+	return ok, nil
+}
+
+// appendKeys adds the keys to the end of the config, leaving what is
+// already there, comments included, untouched.
+func appendKeys(path string, labels []string, keys map[string]key) error {
+	var buf bytes.Buffer
+	for _, label := range labels {
+		fmt.Fprintf(&buf, "\n[key.%v]\n", tomlKey(label))
+		if err := toml.NewEncoder(&buf).Encode(keys[label]); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return configError{fmt.Errorf("unable to write %v: %v", path, err)}
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return configError{fmt.Errorf("unable to write %v: %v", path, err)}
+	}
+	return f.Close()
+}
+
+// tomlKey returns the label as a TOML key, quoted unless it is bare.
+func tomlKey(label string) string {
+	bare := label != ""
+	for _, r := range label {
+		if !(r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) || r == '_' || r == '-') {
+			bare = false
+		}
+	}
+	if bare {
+		return label
+	}
+
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range label {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&b, `\u%04X`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/BurntSushi/toml"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestExportImport(t *testing.T) {
+	good := cmdCfg.subset([]string{"aws", "bank", "gh"})
+
+	for _, format := range managerFormatNames() {
+		export := filepath.Join(t.TempDir(), "export")
+		out, err := runCommand(good, "plain", "export", "-format", format, export)
+		if err != nil {
+			t.Fatalf("%v: %v", format, err)
+		}
+		if out != "exported 3 keys to "+export+"\n" {
+			t.Errorf("%v: unexpected output %q", format, out)
+		}
+		if info, _ := os.Stat(export); info.Mode().Perm() != 0600 {
+			t.Errorf("%v: expected mode 0600, got %v", format, info.Mode().Perm())
+		}
+
+		path := withCfgFile(t, 0600)
+		os.WriteFile(path, []byte("# my keys\n[key.gh]\nsecret = \"NAR5XTDD3EQU22YU\"\n"), 0600)
+		existing := &config{Key: map[string]key{"gh": key{Secret: "NAR5XTDD3EQU22YU"}}}
+		out, err = runCommand(existing, "plain", "import", "-format", format, export)
+		if err != nil {
+			t.Fatalf("%v: %v", format, err)
+		}
+		// XML exports list each group's entries together
+		lines := strings.Split(strings.TrimSpace(out), "\n")
+		sort.Strings(lines)
+		if strings.Join(lines, "\n") != "imported aws\nimported bank\nskipped  gh: label already in use" {
+			t.Errorf("%v: unexpected output %q", format, out)
+		}
+
+		data, _ := os.ReadFile(path)
+		if !strings.HasPrefix(string(data), "# my keys\n") {
+			t.Errorf("%v: expected the config to be kept, got\n%s", format, data)
+		}
+		cfg, err := readCfg()
+		if err != nil {
+			t.Fatalf("%v: %v", format, err)
+		}
+		for _, label := range []string{"aws", "bank"} {
+			want, got := good.Key[label], cfg.Key[label]
+			if format == "keepassxc-legacy" {
+				// the legacy attributes have no issuer
+				want.Issuer = ""
+			}
+			if got.Secret != want.Secret || got.Issuer != want.Issuer || got.period() != want.period() || got.Group != want.Group {
+				t.Errorf("%v: expected %+v, got %+v", format, want, got)
+			}
+		}
+		if cfg.Key["gh"].Secret != "NAR5XTDD3EQU22YU" {
+			t.Errorf("%v: expected gh to be left alone, got %+v", format, cfg.Key["gh"])
+		}
+	}
+}
+
+func TestImportBitwarden(t *testing.T) {
+	withCfgFile(t, 0600)
+	out, err := runCommand(&config{}, "json", "import", "-group", "/imported/", "../bitwarden/testdata/export.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records := []importRecord{}
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		var r importRecord
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, r)
+	}
+	if len(records) != 3 || !records[0].Imported || !records[1].Imported || records[2].Imported || records[2].Reason != "Steam Guard codes are not supported" {
+		t.Errorf("Unexpected records %+v", records)
+	}
+
+	cfg, err := readCfg()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k := cfg.Key["GitHub"]; k.Secret != "MFRGGZDFMZTWQ2LK" || k.Issuer != "GitHub" || k.Group != "imported" || k.Period != 0 {
+		t.Errorf("Unexpected key %+v", k)
+	}
+}
+
+func TestImportExportErrors(t *testing.T) {
+	withCfgFile(t, 0600)
+	tests := []struct {
+		args   []string
+		status int
+	}{
+		{[]string{"import"}, exitUsage},
+		{[]string{"import", "keys.txt"}, exitUsage},
+		{[]string{"import", "-format", "lastpass", "keys.json"}, exitUsage},
+		{[]string{"import", "missing.json"}, exitFailure},
+		{[]string{"import", "-format", "bitwarden", "../keepassxc/testdata/export.xml"}, exitFailure},
+		{[]string{"import", "../keepassxc/testdata/export.csv"}, 0},
+		{[]string{"export"}, exitUsage},
+		{[]string{"export", "a.json", "b.json"}, exitUsage},
+		{[]string{"export", "-format", "bitwarden", "-group", "nope"}, exitNoMatch},
+		{[]string{"export", "-format", "bitwarden", "-group", "bad"}, exitBadKey},
+	}
+	for _, tt := range tests {
+		_, err := runCommand(&config{}, "plain", tt.args...)
+		if tt.args[0] == "export" {
+			_, err = runCommand(cmdCfg, "plain", tt.args...)
+		}
+		if got := exitStatus(err); got != tt.status {
+			t.Errorf("%v: expected status %v, got %v (%v)", tt.args, tt.status, got, err)
+		}
+	}
+
+	_, err := runCommand(&config{}, "plain", "import", "-format", "bitwarden", "-")
+	if err == nil || errors.As(err, &usageError{}) {
+		t.Errorf("Expected empty stdin to fail to parse, got %v", err)
+	}
+}
+
+func TestExportExisting(t *testing.T) {
+	good := cmdCfg.subset([]string{"gh"})
+	export := filepath.Join(t.TempDir(), "vault.json")
+	os.WriteFile(export, []byte("keep me"), 0644)
+
+	if _, err := runCommand(good, "plain", "export", export); exitStatus(err) != exitUsage {
+		t.Errorf("Expected an existing file to be refused, got %v", err)
+	}
+	if data, _ := os.ReadFile(export); string(data) != "keep me" {
+		t.Errorf("Existing file was overwritten with %q", data)
+	}
+
+	if _, err := runCommand(good, "plain", "export", "-force", export); err != nil {
+		t.Fatal(err)
+	}
+	info, _ := os.Stat(export)
+	if data, _ := os.ReadFile(export); !strings.Contains(string(data), "otpauth://") || info.Mode().Perm() != 0600 {
+		t.Errorf("Expected a replacement readable only by you, got %v %q", info.Mode().Perm(), data)
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(export)); len(entries) != 1 {
+		t.Errorf("Expected no temporary files left, got %v", entries)
+	}
+}
+
+func TestExportStdout(t *testing.T) {
+	out, err := runCommand(cmdCfg, "plain", "export", "-format", "bitwarden", "-group", "work/aws")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, `"totp": "otpauth://totp/Amazon:aws?algorithm=SHA1&digits=6&issuer=Amazon&period=30&secret=NAR5XTDD3EQU22YU"`) {
+		t.Errorf("Unexpected export\n%v", out)
+	}
+}
+
+func TestTOMLKey(t *testing.T) {
+	for _, label := range []string{"gh", "my_key-2", "GitHub (work)", `a"b\c`, "tab\there", "日本", ""} {
+		var cfg config
+		doc := "[key." + tomlKey(label) + "]\nsecret = \"X\"\n"
+		if _, err := toml.Decode(doc, &cfg); err != nil {
+			t.Errorf("%q: %v in\n%v", label, err, doc)
+			continue
+		}
+		if cfg.Key[label].Secret != "X" {
+			t.Errorf("%q: expected the label back, got %v", label, cfg.Key)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// matchers are tried in order until one matches a key's label or issuer.
+// Comparisons ignore case.
+var matchers = []func(s, query string) bool{
+	func(s, query string) bool { return s == query },
+	strings.HasPrefix,
+	strings.Contains,
+	isSubsequence,
+}
+
+// isSubsequence reports whether the characters of query appear in s in
+// order, such that "ghb" matches "github".
+func isSubsequence(s, query string) bool {
+	q := []rune(query)
+	for _, r := range s {
+		if len(q) > 0 && r == q[0] {
+			q = q[1:]
+		}
+	}
+	return len(q) == 0
+}
+
+// match returns the sorted labels of the keys matching the query. A label
+// matching exactly wins outright; otherwise labels and issuers are compared
+// using the first of the matchers to find anything.
+func match(cfg *config, query string) []string {
+	if _, ok := cfg.Key[query]; ok {
+		return []string{query}
+	}
+
+	query = strings.ToLower(query)
+	for _, matches := range matchers {
+		found := []string{}
+		for _, label := range cfg.labels() {
+			issuer := cfg.Key[label].Issuer
+			if matches(strings.ToLower(label), query) ||
+				(issuer != "" && matches(strings.ToLower(issuer), query)) {
+				found = append(found, label)
+			}
+		}
+		if len(found) > 0 {
+			return found
+		}
+	}
+	return nil
+}
+
+// suggest returns the label or issuer closest to the query, if any is
+// within a few typos of it.
+func suggest(cfg *config, query string) (string, bool) {
+	best, limit := "", len(query)/3+2
+	for _, label := range cfg.labels() {
+		for _, s := range []string{label, cfg.Key[label].Issuer} {
+			if s == "" {
+				continue
+			}
+			if d := distance(strings.ToLower(s), strings.ToLower(query)); d < limit {
+				best, limit = s, d
+			}
+		}
+	}
+	return best, best != ""
+}
+
+// distance returns the Levenshtein distance between a and b.
+func distance(a, b string) int {
+	s, t := []rune(a), []rune(b)
+	row := make([]int, len(t)+1)
+	for j := range row {
+		row[j] = j
+	}
+	for i := 1; i <= len(s); i++ {
+		prev := row[0]
+		row[0] = i
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			next := minInt(row[j]+1, row[j-1]+1, prev+cost)
+			prev, row[j] = row[j], next
+		}
+	}
+	return row[len(t)]
+}
+
+func minInt(a int, b ...int) int {
+	for _, n := range b {
+		if n < a {
+			a = n
+		}
+	}
+	return a
+}
+
+// pick asks which of the labels to use, reading the choice from r.
+func pick(r io.Reader, w io.Writer, cfg *config, labels []string) (string, error) {
+	for i, label := range labels {
+		fmt.Fprintf(w, "%3d) %v\t%v\n", i+1, label, cfg.Key[label].Issuer)
+	}
+	fmt.Fprintf(w, "select a key [1-%d]: ", len(labels))
+
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line))
+	if err != nil || n < 1 || n > len(labels) {
+		return "", fmt.Errorf("invalid selection %q", strings.TrimSpace(line))
+	}
+	return labels[n-1], nil
+}
+
+// isTerminal reports whether f is connected to a terminal.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// resolve finds the label of the one key matching the query. Several
+// matches are offered as a choice when run interactively, on stderr so that
+// stdout holds only the code.
+func resolve(cfg *config, query string) (string, error) {
+	labels := match(cfg, query)
+	switch {
+	case len(labels) == 1:
+		return labels[0], nil
+	case len(labels) > 1 && isTerminal(os.Stdin) && !structured():
+		fmt.Fprintf(os.Stderr, "%q matches %d keys:\n", query, len(labels))
+		label, err := pick(os.Stdin, os.Stderr, cfg, labels)
+		if err != nil {
+			return "", matchError{err.Error()}
+		}
+		return label, nil
+	case len(labels) > 1:
+		return "", matchError{fmt.Sprintf("%q matches several keys: %v", query, strings.Join(labels, ", "))}
+	}
+
+	if s, ok := suggest(cfg, query); ok {
+		return "", matchError{fmt.Sprintf("no key matches %q; did you mean %q?", query, s)}
+	}
+	return "", matchError{fmt.Sprintf("no key matches %q", query)}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+var matchCfg = &config{
+	Key: map[string]key{
+		"gh":       key{Issuer: "GitHub"},
+		"gitlab":   key{Issuer: "GitLab"},
+		"aws-prod": key{Issuer: "Amazon"},
+		"aws-dev":  key{Issuer: "Amazon"},
+		"g":        key{},
+	},
+}
+
+func TestMatch(t *testing.T) {
+	cases := []struct {
+		query  string
+		labels string
+	}{
+		{"gh", "gh"},
+		{"g", "g"},
+		{"GitHub", "gh"},
+		{"aws", "aws-dev,aws-prod"},
+		{"aws-p", "aws-prod"},
+		{"prod", "aws-prod"},
+		{"amaz", "aws-dev,aws-prod"},
+		{"gtlb", "gitlab"},
+		{"zzz", ""},
+	}
+	for _, c := range cases {
+		if labels := strings.Join(match(matchCfg, c.query), ","); labels != c.labels {
+			t.Errorf("match(%q) = %q, expected %q", c.query, labels, c.labels)
+		}
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	if s, ok := suggest(matchCfg, "gitlba"); !ok || s != "gitlab" {
+		t.Errorf("Expected gitlab as suggestion, got %q", s)
+	}
+	if s, ok := suggest(matchCfg, "Amazom"); !ok || s != "Amazon" {
+		t.Errorf("Expected Amazon as suggestion, got %q", s)
+	}
+	if s, ok := suggest(matchCfg, "bitbucket"); ok {
+		t.Errorf("Unexpected suggestion %q", s)
+	}
+}
+
+func TestDistance(t *testing.T) {
+	if d := distance("kitten", "sitting"); d != 3 {
+		t.Errorf("Expected distance 3, got %d", d)
+	}
+	if d := distance("", "abc"); d != 3 {
+		t.Errorf("Expected distance 3, got %d", d)
+	}
+}
+
+func TestPick(t *testing.T) {
+	var out bytes.Buffer
+	label, err := pick(strings.NewReader("2\n"), &out, matchCfg, []string{"aws-dev", "aws-prod"})
+	if err != nil || label != "aws-prod" {
+		t.Errorf("Expected aws-prod, got %q: %v", label, err)
+	}
+	if !strings.Contains(out.String(), "2) aws-prod") {
+		t.Errorf("Candidates not listed:\n%v", out.String())
+	}
+
+	if _, err := pick(strings.NewReader("3\n"), &out, matchCfg, []string{"aws-dev", "aws-prod"}); err == nil {
+		t.Error("Out of range selection should fail")
+	}
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/tristanwietsma/otp"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var outputFormats = []string{"plain", "json", "csv"}
+
+// output is the format selected by the global -output flag.
+var output = "plain"
+
+// stdout is where commands write their results. Tests replace it to
+// capture them.
+var stdout io.Writer = os.Stdout
+
+// structured reports whether a machine-readable output format was chosen.
+func structured() bool {
+	return output != "plain"
+}
+
+// record is a row of structured output. JSON output writes one object per
+// line; CSV output writes the header before the first row.
+type record interface {
+	header() []string
+	row() []string
+}
+
+type emitter struct {
+	w      io.Writer
+	format string
+	header bool
+}
+
+func newEmitter() *emitter {
+	return &emitter{w: stdout, format: output}
+}
+
+func (e *emitter) emit(r record) error {
+	switch e.format {
+	case "json":
+		return json.NewEncoder(e.w).Encode(r)
+	case "csv":
+		cw := csv.NewWriter(e.w)
+		if !e.header {
+			cw.Write(r.header())
+			e.header = true
+		}
+		cw.Write(r.row())
+		cw.Flush()
+		return cw.Error()
+	}
+	return fmt.Errorf("unknown output format %q", e.format)
+}
+
+// keyRecord describes a key and, if calculated, its current code. Scripts
+// read these records, so fields are only added, at the end: group and tags
+// followed the original six.
+type keyRecord struct {
+	Label     string     `json:"label"`
+	Issuer    string     `json:"issuer"`
+	Code      string     `json:"code"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	Period    int64      `json:"period"`
+	Algorithm string     `json:"algorithm"`
+	Group     string     `json:"group"`
+	Tags      []string   `json:"tags"`
+}
+
+func newKeyRecord(label string, k key) keyRecord {
+	return keyRecord{
+		Label:     label,
+		Issuer:    k.Issuer,
+		Period:    k.period(),
+		Algorithm: k.algorithm(),
+		Group:     k.Group,
+		Tags:      append([]string{}, k.Tags...),
+	}
+}
+
+// withCode calculates the key's current code.
+func (r keyRecord) withCode(k key) (keyRecord, error) {
+	code, _, err := getCode(r.Label, k)
+	if err != nil {
+		return r, err
+	}
+	expires := k.expires().UTC()
+	r.Code = code
+	r.ExpiresAt = &expires
+	return r, nil
+}
+
+func (r keyRecord) header() []string {
+	return []string{"label", "issuer", "code", "expires_at", "period", "algorithm", "group", "tags"}
+}
+
+func (r keyRecord) row() []string {
+	expires := ""
+	if r.ExpiresAt != nil {
+		expires = r.ExpiresAt.Format(time.RFC3339)
+	}
+	return []string{
+		r.Label,
+		r.Issuer,
+		r.Code,
+		expires,
+		strconv.FormatInt(r.Period, 10),
+		r.Algorithm,
+		r.Group,
+		strings.Join(r.Tags, ";"),
+	}
+}
+
+// pathRecord reports the location of a file or service.
+type pathRecord struct {
+	Path    string `json:"path"`
+	Created bool   `json:"created"`
+}
+
+func (r pathRecord) header() []string {
+	return []string{"path", "created"}
+}
+
+func (r pathRecord) row() []string {
+	return []string{r.Path, strconv.FormatBool(r.Created)}
+}
+
+// urlRecord reports the address of a running server.
+type urlRecord struct {
+	URL string `json:"url"`
+}
+
+func (r urlRecord) header() []string {
+	return []string{"url"}
+}
+
+func (r urlRecord) row() []string {
+	return []string{r.URL}
+}
+
+// checkRecord reports the outcome of one of doctor's checks.
+type checkRecord struct {
+	Check   string `json:"check"`
+	Label   string `json:"label"`
+	Level   string `json:"level"`
+	Message string `json:"message"`
+}
+
+func (r checkRecord) header() []string {
+	return []string{"check", "label", "level", "message"}
+}
+
+func (r checkRecord) row() []string {
+	return []string{r.Check, r.Label, r.Level, r.Message}
+}
+
+func (r checkRecord) String() string {
+	if r.Label != "" {
+		return fmt.Sprintf("%-8v %v: %v", r.Level, r.Label, r.Message)
+	}
+	return fmt.Sprintf("%-8v %v", r.Level, r.Message)
+}
+
+// recoveryRecord reports which of a key's recovery codes have been used,
+// numbered from 1 in the order they were printed.
+type recoveryRecord struct {
+	Label     string `json:"label"`
+	Total     int    `json:"total"`
+	Remaining int    `json:"remaining"`
+	Used      []int  `json:"used"`
+}
+
+func newRecoveryRecord(label string, set *otp.RecoveryCodes) recoveryRecord {
+	r := recoveryRecord{Label: label, Total: len(set.Codes), Remaining: set.Remaining(), Used: []int{}}
+	for i, c := range set.Codes {
+		if c.Used {
+			r.Used = append(r.Used, i+1)
+		}
+	}
+	return r
+}
+
+func (r recoveryRecord) header() []string {
+	return []string{"label", "total", "remaining", "used"}
+}
+
+func (r recoveryRecord) row() []string {
+	used := []string{}
+	for _, n := range r.Used {
+		used = append(used, strconv.Itoa(n))
+	}
+	return []string{r.Label, strconv.Itoa(r.Total), strconv.Itoa(r.Remaining), strings.Join(used, ";")}
+}
+
+func (r recoveryRecord) String() string {
+	s := fmt.Sprintf("%v: %d of %d recovery codes left", r.Label, r.Remaining, r.Total)
+	if len(r.Used) > 0 {
+		used := []string{}
+		for _, n := range r.Used {
+			used = append(used, "#"+strconv.Itoa(n))
+		}
+		s += "; used " + strings.Join(used, ", ")
+	}
+	return s
+}
+
+// codeRecord reports a newly generated recovery code.
+type codeRecord struct {
+	Label  string `json:"label"`
+	Number int    `json:"number"`
+	Code   string `json:"code"`
+}
+
+func (r codeRecord) header() []string {
+	return []string{"label", "number", "code"}
+}
+
+func (r codeRecord) row() []string {
+	return []string{r.Label, strconv.Itoa(r.Number), r.Code}
+}
+
+// importRecord reports whether an entry of an export was imported.
+type importRecord struct {
+	Label    string `json:"label"`
+	Imported bool   `json:"imported"`
+	Reason   string `json:"reason"`
+}
+
+func (r importRecord) header() []string {
+	return []string{"label", "imported", "reason"}
+}
+
+func (r importRecord) row() []string {
+	return []string{r.Label, strconv.FormatBool(r.Imported), r.Reason}
+}
+
+func (r importRecord) String() string {
+	if r.Imported {
+		return "imported " + r.Label
+	}
+	return fmt.Sprintf("skipped  %v: %v", r.Label, r.Reason)
+}
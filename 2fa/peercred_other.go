@@ -0,0 +1,16 @@
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"net"
+)
+
+// havePeerUID reports whether peerUID can identify clients.
+const havePeerUID = false
+
+// peerUID is only supported on Linux; elsewhere, clients must use a token.
+func peerUID(c net.Conn) (int, error) {
+	return 0, errors.New("peer credentials are not supported on this platform")
+}
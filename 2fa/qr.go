@@ -1,50 +1,96 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"github.com/tristanwietsma/otp"
-	"github.com/tristanwietsma/rsc/qr"
+	"time"
 )
 
-type qrCommand struct{}
+type qrCommand struct {
+	opts  serveOptions
+	group string
+	tags  tagsFlag
+}
 
-func (q qrCommand) Name() string {
+func (q *qrCommand) Name() string {
 	return "qrcodes"
 }
 
-func (q qrCommand) Run(args []string) bool {
-	cfg := getCfg()
+func (q *qrCommand) FlagSet() *flag.FlagSet {
+	fs := newFlagSet(q.Name())
+	fs.StringVar(&q.opts.addr, "addr", "127.0.0.1:3000", "")
+	fs.BoolVar(&q.opts.tls, "tls", false, "")
+	fs.BoolVar(&q.opts.once, "once", false, "")
+	fs.DurationVar(&q.opts.timeout, "timeout", 5*time.Minute, "")
+	fs.StringVar(&q.group, "group", "", "")
+	q.tags = nil
+	fs.Var(&q.tags, "tag", "")
+	return fs
+}
+
+func (q *qrCommand) Run(args []string) error {
+	fs := q.FlagSet()
+	if err := fs.Parse(args); err != nil {
+		return errUsage("%v", err)
+	}
+
+	cfg, err := loadCfg()
+	if err != nil {
+		return err
+	}
+	cfg = cfg.subset(cfg.filter(q.group, q.tags))
+	labels := cfg.labels()
+	if fs.NArg() > 0 {
+		labels = []string{}
+		for _, query := range fs.Args() {
+			label, err := resolve(cfg, query)
+			if err != nil {
+				return err
+			}
+			labels = append(labels, label)
+		}
+	}
+	if len(labels) == 0 {
+		return matchError{"no keys match the group and tags"}
+	}
 
-	qrCodes := []*qr.Code{}
-	for name := range cfg.Key {
+	images := []qrImage{}
+	for _, name := range labels {
 		issuer := cfg.Key[name].Issuer
 		secret := cfg.Key[name].Secret
+		period := int(cfg.Key[name].period())
 
-		k, err := otp.NewTOTPKey(name, secret, issuer, otp.Hashes[0], 6, 30)
+		k, err := otp.NewTOTPKey(name, secret, issuer, otp.Hashes[0], 6, period)
 		if err != nil {
-			fmt.Printf("unable to generate key for %s\n", name)
-			return false
+			return keyError{name, err}
 		}
 
 		qr, err := k.QrCode()
 		if err != nil {
-			fmt.Printf("unable to generate QR code for %s\n", name)
-			return false
+			return fmt.Errorf("unable to generate QR code for %s: %v", name, err)
 		}
-		qrCodes = append(qrCodes, qr)
+		images = append(images, qrImage{name, issuer, qr.PNG()})
 	}
 
-	serve(qrCodes)
-	return true
+	return serve(images, q.opts)
 }
 
-func (q qrCommand) Usage() {
+func (q *qrCommand) Usage() {
 	usage := "    qrcodes     start server with qr codes"
 	fmt.Println(usage)
 }
 
-func (q qrCommand) Help() {
-	help := "\n" + q.Name() + " usage:\n\n    totp " + q.Name() + "\n\n"
-	help += "    Displays QR codes for all keys stored in " + getCfgPath() + ".\n"
+func (q *qrCommand) Help() {
+	help := "\n" + q.Name() + " usage:\n\n    totp " + q.Name() + " [-addr host:port] [-tls] [-once] [-timeout duration]\n"
+	help += "                [-group group] [-tag tag]... [label...]\n\n"
+	help += "    Displays QR codes for the labeled keys, or all keys, stored in " + getCfgPath() + ".\n"
+	help += "    The page is served once from a random URL that is printed on startup.\n\n"
+	help += "    -addr       listen address (default 127.0.0.1:3000)\n"
+	help += "    -tls        serve over https with a throwaway self-signed certificate\n"
+	help += "    -once       stop as soon as the page has been viewed\n"
+	help += "    -timeout    stop after a duration (default 5m); 0 serves until interrupted\n"
+	help += "    -group      only show keys in the group or a folder beneath it\n"
+	help += "    -tag        only show keys with the tag; may be repeated\n"
 	fmt.Println(help)
 }
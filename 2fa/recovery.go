@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/tristanwietsma/otp"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// stdin is read by recovery -import and -use; tests replace it.
+var stdin io.Reader = os.Stdin
+
+type recoveryCommand struct {
+	generate  int
+	importing bool
+	using     bool
+}
+
+func (c *recoveryCommand) Name() string {
+	return "recovery"
+}
+
+func (c *recoveryCommand) FlagSet() *flag.FlagSet {
+	fs := newFlagSet(c.Name())
+	fs.IntVar(&c.generate, "new", 0, "")
+	fs.BoolVar(&c.importing, "import", false, "")
+	fs.BoolVar(&c.using, "use", false, "")
+	return fs
+}
+
+func (c *recoveryCommand) Run(args []string) error {
+	fs := c.FlagSet()
+	if err := fs.Parse(args); err != nil {
+		return errUsage("%v", err)
+	}
+	if fs.NArg() != 1 {
+		return errUsage("recovery takes a label")
+	}
+	modes := 0
+	for _, set := range []bool{c.generate != 0, c.importing, c.using} {
+		if set {
+			modes++
+		}
+	}
+	if modes > 1 {
+		return errUsage("use only one of -new, -import and -use")
+	}
+	if c.generate < 0 {
+		return errUsage("-new takes a positive number of codes")
+	}
+
+	cfg, err := loadCfg()
+	if err != nil {
+		return err
+	}
+	label, err := resolve(cfg, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	path, err := recoveryPath()
+	if err != nil {
+		return err
+	}
+	sets, err := readRecovery(path)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case c.generate > 0:
+		set, codes, err := otp.NewRecoveryCodes(c.generate)
+		if err != nil {
+			return err
+		}
+		sets[label] = set
+		if err := writeRecovery(path, sets); err != nil {
+			return err
+		}
+		return printCodes(label, codes)
+
+	case c.importing:
+		codes, err := readCodes(stdin)
+		if err != nil {
+			return err
+		}
+		set, err := otp.HashRecoveryCodes(codes)
+		if err != nil {
+			return errUsage("no codes to import; give one per line on stdin")
+		}
+		sets[label] = set
+		if err := writeRecovery(path, sets); err != nil {
+			return err
+		}
+
+	case c.using:
+		set, ok := sets[label]
+		if !ok {
+			return matchError{fmt.Sprintf("no recovery codes for %v; create them with -new or -import", label)}
+		}
+		// read from stdin, the code stays out of shell history and ps
+		if f, ok := stdin.(*os.File); ok && isTerminal(f) {
+			fmt.Fprint(os.Stderr, "recovery code: ")
+		}
+		codes, err := readCodes(io.LimitReader(stdin, 1024))
+		if err != nil {
+			return err
+		}
+		if len(codes) != 1 {
+			return errUsage("-use reads one code from stdin")
+		}
+		i, ok := set.Consume(codes[0])
+		if !ok {
+			return fmt.Errorf("%q is not an unused recovery code for %v", codes[0], label)
+		}
+		if err := writeRecovery(path, sets); err != nil {
+			return err
+		}
+		if !structured() {
+			fmt.Fprintf(stdout, "used code #%d\n", i+1)
+		}
+
+	default:
+		if _, ok := sets[label]; !ok {
+			return matchError{fmt.Sprintf("no recovery codes for %v; create them with -new or -import", label)}
+		}
+	}
+
+	r := newRecoveryRecord(label, sets[label])
+	if structured() {
+		return newEmitter().emit(r)
+	}
+	fmt.Fprintln(stdout, r)
+	return nil
+}
+
+// printCodes shows newly generated codes, numbered to match the record of
+// which have been used.
+func printCodes(label string, codes []string) error {
+	if structured() {
+		e := newEmitter()
+		for i, code := range codes {
+			if err := e.emit(codeRecord{label, i + 1, code}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	fmt.Fprintf(stdout, "recovery codes for %v; print or store them, they won't be shown again:\n\n", label)
+	for i, code := range codes {
+		fmt.Fprintf(stdout, "%4d. %v\n", i+1, code)
+	}
+	return nil
+}
+
+// readCodes returns the non-blank lines of r.
+func readCodes(r io.Reader) ([]string, error) {
+	codes := []string{}
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		if line := strings.TrimSpace(s.Text()); line != "" {
+			codes = append(codes, line)
+		}
+	}
+	return codes, s.Err()
+}
+
+// recoveryPath returns the file holding recovery code hashes, which sits
+// beside the config and shares its name, so each profile has its own.
+func recoveryPath() (string, error) {
+	path, err := findCfgPath(cfgFlag, profileFlag)
+	if err != nil {
+		return "", configError{err}
+	}
+	return strings.TrimSuffix(path, filepath.Ext(path)) + ".recovery.json", nil
+}
+
+// readRecovery returns the sets of recovery codes by label; a missing file
+// has none.
+func readRecovery(path string) (map[string]*otp.RecoveryCodes, error) {
+	sets := map[string]*otp.RecoveryCodes{}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return sets, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &sets); err != nil {
+		return nil, fmt.Errorf("unable to read %v: %v", path, err)
+	}
+	return sets, nil
+}
+
+// writeRecovery replaces the file with the sets, readable only by you.
+func writeRecovery(path string, sets map[string]*otp.RecoveryCodes) error {
+	data, err := json.MarshalIndent(sets, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	return replaceFile(path, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
+
+func (c *recoveryCommand) Complete(args []string) []string {
+	if len(args) > 0 {
+		return nil
+	}
+	return completeLabels()
+}
+
+func (c *recoveryCommand) Usage() {
+	usage := "    recovery    create and track recovery codes"
+	fmt.Println(usage)
+}
+
+func (c *recoveryCommand) Help() {
+	help := "\n" + c.Name() + " usage:\n\n    2fa " + c.Name() + " [-new n | -import | -use] label\n\n"
+	help += "    Tracks which of a key's single-use recovery codes are left. Only salted\n"
+	help += "    hashes of the codes are kept, beside " + getCfgPath() + ".\n"
+	help += "    Without a flag, shows how many codes are left.\n\n"
+	help += "    -new        generate and print a new set of n codes, replacing any others\n"
+	help += "    -import     read a set of codes issued by a service from stdin, one per line\n"
+	help += "    -use        read a code from stdin and mark it as used\n"
+	fmt.Println(help)
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecoveryCommand(t *testing.T) {
+	cfgPath := withCfgFile(t, 0600)
+
+	out, err := runCommand(cmdCfg, "json", "recovery", "-new", "4", "gh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	codes := []string{}
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		var r codeRecord
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			t.Fatal(err)
+		}
+		codes = append(codes, r.Code)
+	}
+	if len(codes) != 4 {
+		t.Fatalf("Expected 4 codes, got %q", out)
+	}
+
+	path := strings.TrimSuffix(cfgPath, ".toml") + ".recovery.json"
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), codes[0]) {
+		t.Error("Codes should not be stored in plain text")
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0600 {
+		t.Errorf("Expected mode 0600, got %v", info.Mode().Perm())
+	}
+
+	saved := stdin
+	defer func() { stdin = saved }()
+	stdin = strings.NewReader(codes[2] + "\n")
+	out, err = runCommand(cmdCfg, "plain", "recovery", "-use", "gh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "used code #3\ngh: 3 of 4 recovery codes left; used #3\n" {
+		t.Errorf("Unexpected output %q", out)
+	}
+	stdin = strings.NewReader(codes[2])
+	if _, err := runCommand(cmdCfg, "plain", "recovery", "-use", "gh"); exitStatus(err) != exitFailure {
+		t.Errorf("Expected a used code to be refused, got %v", err)
+	}
+
+	out, err = runCommand(cmdCfg, "csv", "recovery", "gh")
+	if err != nil || out != "label,total,remaining,used\ngh,4,3,3\n" {
+		t.Errorf("Unexpected status %q (%v)", out, err)
+	}
+}
+
+func TestRecoveryImport(t *testing.T) {
+	withCfgFile(t, 0600)
+	saved := stdin
+	defer func() { stdin = saved }()
+	stdin = strings.NewReader("abcd-1234\n\n  efgh-5678  \n")
+
+	out, err := runCommand(cmdCfg, "plain", "recovery", "-import", "aws")
+	if err != nil || out != "aws: 2 of 2 recovery codes left\n" {
+		t.Errorf("Unexpected output %q (%v)", out, err)
+	}
+	stdin = strings.NewReader("EFGH5678\n")
+	if _, err := runCommand(cmdCfg, "plain", "recovery", "-use", "aws"); err != nil {
+		t.Errorf("Expected the imported code to be accepted, got %v", err)
+	}
+
+	stdin = strings.NewReader("\n")
+	if _, err := runCommand(cmdCfg, "plain", "recovery", "-import", "aws"); exitStatus(err) != exitUsage {
+		t.Errorf("Expected an empty import to be refused, got %v", err)
+	}
+}
+
+func TestRecoveryErrors(t *testing.T) {
+	dir := filepath.Dir(withCfgFile(t, 0600))
+	tests := []struct {
+		args   []string
+		status int
+	}{
+		{[]string{"recovery"}, exitUsage},
+		{[]string{"recovery", "-new", "-2", "gh"}, exitUsage},
+		{[]string{"recovery", "-new", "2", "-use", "gh"}, exitUsage},
+		{[]string{"recovery", "-use", "x", "gh"}, exitUsage},
+		{[]string{"recovery", "zzzzzz"}, exitNoMatch},
+		{[]string{"recovery", "gh"}, exitNoMatch},
+		{[]string{"recovery", "-use", "gh"}, exitNoMatch},
+	}
+
+	for _, test := range tests {
+		_, err := runCommand(cmdCfg, "plain", test.args...)
+		if status := exitStatus(err); status != test.status {
+			t.Errorf("Expected status %v for %q, got %v (%v)", test.status, test.args, status, err)
+		}
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("Expected failed commands to leave no files, got %v", entries)
+	}
+}
@@ -1,33 +1,223 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
-	"github.com/tristanwietsma/rsc/qr"
-	"log"
+	"html/template"
+	"math/big"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
-func serve(qrs []*qr.Code) {
-	page := "<html><body>"
-	mux := http.NewServeMux()
-	for i := range qrs {
-		page += fmt.Sprintf(
-			`<img src="/image/%v.png" width="300px">`, i)
-		func(j int, img *qr.Code) {
-			mux.HandleFunc(
-				fmt.Sprintf("/image/%v.png", j),
-				func(w http.ResponseWriter, r *http.Request) {
-					w.Header().Set("Content-Type", "image/png")
-					w.Write(img.PNG())
-				})
-		}(i, qrs[i])
-	}
-	page += "</body></html>"
-	mux.HandleFunc(
-		"/",
-		func(w http.ResponseWriter, r *http.Request) {
-			w.Write([]byte(page))
-		})
-	fmt.Println("serving QR codes at http://localhost:3000")
-	log.Fatal(http.ListenAndServe(":3000", mux))
+// qrImage is a QR code shown on the served page.
+type qrImage struct {
+	Label  string
+	Issuer string
+	PNG    []byte
+}
+
+// Src returns the image as a data URI, so the page needs no other requests.
+func (i qrImage) Src() template.URL {
+	return template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(i.PNG))
+}
+
+var qrPage = template.Must(template.New("qrcodes").Parse(`<!DOCTYPE html>
+<html><head><title>2fa</title></head><body>
+{{range .}}<figure style="display:inline-block">
+<img src="{{.Src}}" width="300" alt="{{.Label}}">
+<figcaption>{{.Label}}{{if .Issuer}} ({{.Issuer}}){{end}}</figcaption>
+</figure>
+{{end}}</body></html>
+`))
+
+// serveOptions configures the QR code server.
+type serveOptions struct {
+	addr    string
+	tls     bool
+	once    bool
+	timeout time.Duration
+}
+
+// newToken returns a random string for use in URLs.
+func newToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// qrHandler serves the page at /token once, calling viewed after the view.
+// Every other path, and the token once used, is not found.
+func qrHandler(page []byte, token string, viewed func()) http.Handler {
+	var used int32
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.URL.Path), []byte("/"+token)) != 1 {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != "GET" {
+			w.Header().Set("Allow", "GET")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		// concurrent requests race for the token; only the first wins
+		if !atomic.CompareAndSwapInt32(&used, 0, 1) {
+			http.NotFound(w, r)
+			return
+		}
+
+		h := w.Header()
+		h.Set("Content-Type", "text/html; charset=utf-8")
+		h.Set("Cache-Control", "no-store")
+		h.Set("Referrer-Policy", "no-referrer")
+		h.Set("X-Frame-Options", "DENY")
+		h.Set("Content-Security-Policy", "default-src 'none'; img-src data:; style-src 'unsafe-inline'")
+		w.Write(page)
+		viewed()
+	})
+}
+
+// selfSignedCert returns a short-lived certificate for the hosts along with
+// its SHA-256 fingerprint.
+func selfSignedCert(hosts []string) (tls.Certificate, string, error) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, "", err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, "", err
+	}
+
+	tmpl := x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: "2fa qrcodes"},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+		} else if h != "" {
+			tmpl.DNSNames = append(tmpl.DNSNames, h)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &tmpl, &tmpl, &priv.PublicKey, priv)
+	if err != nil {
+		return tls.Certificate{}, "", err
+	}
+	sum := sha256.Sum256(der)
+	cert := tls.Certificate{Certificate: [][]byte{der}, PrivateKey: priv}
+	return cert, hex.EncodeToString(sum[:]), nil
+}
+
+// urlHost returns the host to put in URLs for a listener address.
+func urlHost(addr net.Addr) string {
+	host, port, _ := net.SplitHostPort(addr.String())
+	if ip := net.ParseIP(host); ip != nil && ip.IsUnspecified() {
+		if name, err := os.Hostname(); err == nil {
+			host = name
+		}
+	}
+	return net.JoinHostPort(host, port)
+}
+
+func serve(images []qrImage, opts serveOptions) error {
+	var page bytes.Buffer
+	if err := qrPage.Execute(&page, images); err != nil {
+		return fmt.Errorf("unable to render page: %v", err)
+	}
+	token, err := newToken()
+	if err != nil {
+		return fmt.Errorf("unable to generate access token: %v", err)
+	}
+
+	ln, err := net.Listen("tcp", opts.addr)
+	if err != nil {
+		return err
+	}
+	defer ln.Close()
+	host := urlHost(ln.Addr())
+	if !ln.Addr().(*net.TCPAddr).IP.IsLoopback() {
+		fmt.Fprintln(os.Stderr, "warning: secrets are reachable from other machines at", host)
+	}
+
+	scheme, fingerprint := "http", ""
+	if opts.tls {
+		h, _, _ := net.SplitHostPort(host)
+		cert, sum, err := selfSignedCert([]string{h, "localhost", "127.0.0.1", "::1"})
+		if err != nil {
+			return fmt.Errorf("unable to generate certificate: %v", err)
+		}
+		ln = tls.NewListener(ln, &tls.Config{Certificates: []tls.Certificate{cert}})
+		scheme, fingerprint = "https", sum
+	}
+
+	viewed := make(chan struct{})
+	var once sync.Once
+	srv := &http.Server{
+		Handler: qrHandler(page.Bytes(), token, func() {
+			once.Do(func() { close(viewed) })
+		}),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	stop := (<-chan struct{})(viewed)
+	if !opts.once {
+		stop = nil
+	}
+
+	url := scheme + "://" + host + "/" + token
+	if structured() {
+		if err := newEmitter().emit(urlRecord{url}); err != nil {
+			return err
+		}
+	} else {
+		fmt.Fprintln(stdout, "serving QR codes at "+url)
+		if fingerprint != "" {
+			fmt.Fprintln(stdout, "certificate SHA-256 fingerprint "+fingerprint)
+		}
+	}
+
+	failed := make(chan error, 1)
+	go func() { failed <- srv.Serve(ln) }()
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+	var expired <-chan time.Time
+	if opts.timeout > 0 {
+		expired = time.After(opts.timeout)
+	}
+
+	select {
+	case err := <-failed:
+		return err
+	case <-stop:
+	case <-expired:
+	case <-interrupt:
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return srv.Shutdown(ctx)
 }
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestQRHandler(t *testing.T) {
+	views := 0
+	h := qrHandler([]byte("page"), "secret-token", func() { views++ })
+
+	for _, path := range []string{"/", "/secret", "/secret-token/", "/image/0.png"} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Code != http.StatusNotFound {
+			t.Errorf("Expected 404 for %v, got %v", path, w.Code)
+		}
+	}
+	if views != 0 {
+		t.Errorf("Unauthorized requests counted as views")
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/secret-token", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "page" {
+		t.Errorf("Page not served: %v %q", w.Code, w.Body.String())
+	}
+	if w.Header().Get("Cache-Control") != "no-store" {
+		t.Error("Page should not be cached")
+	}
+	if views != 1 {
+		t.Errorf("Expected 1 view, got %v", views)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/secret-token", nil))
+	if w.Code != http.StatusNotFound || views != 1 {
+		t.Errorf("Token was reused: %v, %v views", w.Code, views)
+	}
+}
+
+func TestQRHandlerConcurrent(t *testing.T) {
+	var views int32
+	h := qrHandler([]byte("page"), "secret-token", func() { atomic.AddInt32(&views, 1) })
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/secret-token", nil))
+		}()
+	}
+	wg.Wait()
+	if views != 1 {
+		t.Errorf("Expected 1 view, got %v", views)
+	}
+}
+
+func TestQRPage(t *testing.T) {
+	var page strings.Builder
+	images := []qrImage{{Label: "<gh>", Issuer: "GitHub", PNG: []byte{1, 2, 3}}}
+	if err := qrPage.Execute(&page, images); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(page.String(), `src="data:image/png;base64,AQID"`) {
+		t.Errorf("Image not inlined:\n%v", page.String())
+	}
+	if !strings.Contains(page.String(), "&lt;gh&gt; (GitHub)") {
+		t.Errorf("Label not escaped:\n%v", page.String())
+	}
+}
+
+func TestSelfSignedCert(t *testing.T) {
+	cert, fingerprint, err := selfSignedCert([]string{"localhost", "127.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fingerprint) != 64 {
+		t.Errorf("Unexpected fingerprint %q", fingerprint)
+	}
+	c, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.VerifyHostname("127.0.0.1"); err != nil {
+		t.Error(err)
+	}
+	if err := c.VerifyHostname("localhost"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNewToken(t *testing.T) {
+	a, _ := newToken()
+	b, _ := newToken()
+	if len(a) < 20 || a == b {
+		t.Errorf("Tokens are not random: %q %q", a, b)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net"
+	"time"
+)
+
+// ntpEpoch is the start of NTP time, 1900-01-01, in Unix seconds.
+const ntpEpoch = -2208988800
+
+// toNTP encodes t as a 64-bit NTP timestamp.
+func toNTP(t time.Time) uint64 {
+	secs := uint64(t.Unix() - ntpEpoch)
+	frac := uint64(t.Nanosecond()) << 32 / 1e9
+	return secs<<32 | frac
+}
+
+// fromNTP decodes a 64-bit NTP timestamp.
+func fromNTP(ts uint64) time.Time {
+	secs := int64(ts>>32) + ntpEpoch
+	nsecs := int64((ts & 0xffffffff) * 1e9 >> 32)
+	return time.Unix(secs, nsecs)
+}
+
+// sntpOffset asks the SNTP server at addr, a host and port, for the time
+// and returns how far the local clock is behind it. A negative offset means
+// the local clock is ahead.
+func sntpOffset(addr string, timeout time.Duration) (time.Duration, error) {
+	conn, err := net.DialTimeout("udp", addr, timeout)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	// version 4, client mode; the transmit time is echoed back as the
+	// originate time, which ties the reply to this request
+	req := make([]byte, 48)
+	req[0] = 4<<3 | 3
+	sent := time.Now()
+	binary.BigEndian.PutUint64(req[40:], toNTP(sent))
+	if _, err := conn.Write(req); err != nil {
+		return 0, err
+	}
+
+	resp := make([]byte, 48)
+	n, err := conn.Read(resp)
+	received := time.Now()
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case n < 48:
+		return 0, errors.New("short reply from time server")
+	case resp[0]&7 != 4:
+		return 0, errors.New("reply from time server is not in server mode")
+	case resp[1] == 0:
+		return 0, errors.New("time server refused the request")
+	case !bytes.Equal(resp[24:32], req[40:48]):
+		return 0, errors.New("reply from time server does not match the request")
+	}
+
+	// the server's receive and transmit times bracket its processing;
+	// averaging both legs cancels out a symmetric network delay
+	rx := fromNTP(binary.BigEndian.Uint64(resp[32:]))
+	tx := fromNTP(binary.BigEndian.Uint64(resp[40:]))
+	return (rx.Sub(sent) + tx.Sub(received)) / 2, nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+)
+
+// fakeSNTP answers SNTP requests as a server whose clock is skewed ahead of
+// the local one, returning its address.
+func fakeSNTP(t *testing.T, skew time.Duration) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		req := make([]byte, 48)
+		for {
+			n, addr, err := conn.ReadFrom(req)
+			if err != nil {
+				return
+			}
+			if n < 48 {
+				continue
+			}
+			resp := make([]byte, 48)
+			resp[0] = 4<<3 | 4
+			resp[1] = 2
+			copy(resp[24:32], req[40:48])
+			now := toNTP(time.Now().Add(skew))
+			binary.BigEndian.PutUint64(resp[32:], now)
+			binary.BigEndian.PutUint64(resp[40:], now)
+			conn.WriteTo(resp, addr)
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func TestNTPTime(t *testing.T) {
+	now := time.Unix(1433160000, 250000000)
+	if got := fromNTP(toNTP(now)); got.Sub(now).Abs() > time.Microsecond {
+		t.Errorf("Expected %v, got %v", now, got)
+	}
+	if got := toNTP(time.Unix(0, 0)) >> 32; got != 2208988800 {
+		t.Errorf("Expected the Unix epoch at 2208988800, got %v", got)
+	}
+}
+
+func TestSNTPOffset(t *testing.T) {
+	for _, skew := range []time.Duration{0, time.Hour, -90 * time.Second} {
+		offset, err := sntpOffset(fakeSNTP(t, skew), time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (offset - skew).Abs() > 100*time.Millisecond {
+			t.Errorf("Expected an offset near %v, got %v", skew, offset)
+		}
+	}
+}
+
+func TestSNTPNoReply(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if _, err := sntpOffset(conn.LocalAddr().String(), 50*time.Millisecond); err == nil {
+		t.Error("Expected an error without a reply")
+	}
+}
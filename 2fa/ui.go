@@ -0,0 +1,347 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+type uiCommand struct{}
+
+func (c *uiCommand) Name() string {
+	return "ui"
+}
+
+func (c *uiCommand) FlagSet() *flag.FlagSet {
+	return newFlagSet(c.Name())
+}
+
+func (c *uiCommand) Run(args []string) error {
+	fs := c.FlagSet()
+	if err := fs.Parse(args); err != nil {
+		return errUsage("%v", err)
+	}
+	if fs.NArg() != 0 {
+		return errUsage("ui takes no arguments")
+	}
+	if !isTerminal(os.Stdin) || !isTerminal(os.Stdout) {
+		return errors.New("ui must be run in a terminal")
+	}
+	cfg, err := loadCfg()
+	if err != nil {
+		return err
+	}
+	if len(cfg.Key) == 0 {
+		return matchError{fmt.Sprintf("no keys in %v", getCfgPath())}
+	}
+	return runUI(newUIModel(cfg), newClipboard())
+}
+
+func (c *uiCommand) Usage() {
+	usage := "    ui          browse and copy codes in a full-screen view"
+	fmt.Println(usage)
+}
+
+func (c *uiCommand) Help() {
+	help := "\n" + c.Name() + " usage:\n\n    2fa " + c.Name() + "\n\n"
+	help += "    Shows live codes for all keys stored in " + getCfgPath() + ".\n"
+	help += "    Codes are hidden until revealed.\n\n"
+	help += "    up, down, j, k    move the selection\n"
+	help += "    /                 search labels and issuers; enter or esc to finish\n"
+	help += "    r                 reveal or hide codes\n"
+	help += "    enter, c          copy the selected code, clearing it once expired\n"
+	help += "    q, ctrl-c         quit\n"
+	fmt.Println(help)
+}
+
+// uiModel is the state of the terminal UI, separate from the terminal so it
+// can be driven in tests.
+type uiModel struct {
+	cfg       *config
+	query     string
+	searching bool
+	cursor    int
+	revealed  bool
+	status    string
+}
+
+func newUIModel(cfg *config) *uiModel {
+	return &uiModel{cfg: cfg}
+}
+
+// visible returns the labels of the keys matching the search.
+func (m *uiModel) visible() []string {
+	labels := []string{}
+	query := strings.ToLower(m.query)
+	for _, label := range m.cfg.labels() {
+		if isSubsequence(strings.ToLower(label), query) ||
+			isSubsequence(strings.ToLower(m.cfg.Key[label].Issuer), query) {
+			labels = append(labels, label)
+		}
+	}
+	return labels
+}
+
+// selected returns the label under the cursor, if any.
+func (m *uiModel) selected() (string, bool) {
+	labels := m.visible()
+	if len(labels) == 0 {
+		return "", false
+	}
+	if m.cursor >= len(labels) {
+		m.cursor = len(labels) - 1
+	}
+	return labels[m.cursor], true
+}
+
+// handle applies a key press, returning whether to quit and the label of a
+// key whose code should be copied.
+func (m *uiModel) handle(key string) (quit bool, copyLabel string) {
+	if key == "ctrl-c" {
+		return true, ""
+	}
+
+	if m.searching {
+		switch key {
+		case "enter", "esc":
+			m.searching = false
+		case "backspace":
+			if len(m.query) > 0 {
+				r := []rune(m.query)
+				m.query = string(r[:len(r)-1])
+			}
+		case "up", "down":
+			m.move(key)
+		default:
+			if len([]rune(key)) == 1 {
+				m.query += key
+				m.cursor = 0
+			}
+		}
+		return false, ""
+	}
+
+	switch key {
+	case "q":
+		return true, ""
+	case "up", "k", "down", "j":
+		m.move(key)
+	case "/":
+		m.searching = true
+	case "esc":
+		m.query = ""
+	case "r":
+		m.revealed = !m.revealed
+	case "enter", "c":
+		if label, ok := m.selected(); ok {
+			return false, label
+		}
+	}
+	return false, ""
+}
+
+func (m *uiModel) move(key string) {
+	n := len(m.visible())
+	switch {
+	case (key == "up" || key == "k") && m.cursor > 0:
+		m.cursor--
+	case (key == "down" || key == "j") && m.cursor < n-1:
+		m.cursor++
+	}
+}
+
+// render draws the screen, using at most height lines.
+func (m *uiModel) render(w io.Writer, height int) {
+	var b strings.Builder
+	// redraw over the previous screen rather than clearing it, to avoid flicker
+	b.WriteString("\033[H")
+	b.WriteString("2fa   / search   r reveal   enter copy   q quit\033[K\r\n")
+	if m.searching || m.query != "" {
+		cursor := ""
+		if m.searching {
+			cursor = "_"
+		}
+		fmt.Fprintf(&b, "search: %v%v\033[K\r\n", m.query, cursor)
+	} else {
+		b.WriteString("\033[K\r\n")
+	}
+
+	labels := m.visible()
+	if m.cursor >= len(labels) && len(labels) > 0 {
+		m.cursor = len(labels) - 1
+	}
+	width := 0
+	for _, label := range labels {
+		if len(label) > width {
+			width = len(label)
+		}
+	}
+
+	// scroll to keep the cursor on screen
+	rows := height - 4
+	if rows < 1 {
+		rows = 1
+	}
+	first := 0
+	if m.cursor >= rows {
+		first = m.cursor - rows + 1
+	}
+	for i := first; i < len(labels) && i < first+rows; i++ {
+		pointer := "  "
+		if i == m.cursor {
+			pointer = "> "
+		}
+		b.WriteString(pointer + watchLine(labels[i], m.cfg.Key[labels[i]], width, !m.revealed) + "\033[K\r\n")
+	}
+	if len(labels) == 0 {
+		b.WriteString("  no matching keys\033[K\r\n")
+	}
+
+	fmt.Fprintf(&b, "\033[K\r\n%v\033[K\033[J", m.status)
+	io.WriteString(w, b.String())
+}
+
+// readKeys sends the keys pressed on r, named as handle expects.
+func readKeys(r io.Reader, keys chan<- string) {
+	buf := make([]byte, 64)
+	for {
+		n, err := r.Read(buf)
+		if err != nil {
+			close(keys)
+			return
+		}
+		for _, key := range parseKeys(buf[:n]) {
+			keys <- key
+		}
+	}
+}
+
+// parseKeys splits raw terminal input into named keys.
+func parseKeys(b []byte) []string {
+	keys := []string{}
+	for len(b) > 0 {
+		switch {
+		case len(b) >= 3 && b[0] == 0x1b && b[1] == '[' && b[2] == 'A':
+			keys, b = append(keys, "up"), b[3:]
+		case len(b) >= 3 && b[0] == 0x1b && b[1] == '[' && b[2] == 'B':
+			keys, b = append(keys, "down"), b[3:]
+		case len(b) >= 2 && b[0] == 0x1b && b[1] == '[':
+			// ignore other sequences
+			b = b[2:]
+			for len(b) > 0 && (b[0] < 0x40 || b[0] > 0x7e) {
+				b = b[1:]
+			}
+			if len(b) > 0 {
+				b = b[1:]
+			}
+		case b[0] == 0x1b:
+			keys, b = append(keys, "esc"), b[1:]
+		case b[0] == 0x03:
+			keys, b = append(keys, "ctrl-c"), b[1:]
+		case b[0] == '\r' || b[0] == '\n':
+			keys, b = append(keys, "enter"), b[1:]
+		case b[0] == 0x7f || b[0] == 0x08:
+			keys, b = append(keys, "backspace"), b[1:]
+		case b[0] < 0x20:
+			b = b[1:]
+		default:
+			r, size := utf8.DecodeRune(b)
+			if r != utf8.RuneError {
+				keys = append(keys, string(r))
+			}
+			b = b[size:]
+		}
+	}
+	return keys
+}
+
+// stty runs stty against the terminal on stdin.
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	return strings.TrimSpace(string(out)), err
+}
+
+// terminalHeight returns the number of rows in the terminal.
+func terminalHeight() int {
+	var rows, cols int
+	if size, err := stty("size"); err == nil {
+		if _, err := fmt.Sscan(size, &rows, &cols); err == nil && rows > 0 {
+			return rows
+		}
+	}
+	return 24
+}
+
+func runUI(m *uiModel, clip clipboard) error {
+	saved, err := stty("-g")
+	if err != nil {
+		return fmt.Errorf("unable to configure terminal: %v", err)
+	}
+	if _, err := stty("raw", "-echo"); err != nil {
+		return fmt.Errorf("unable to configure terminal: %v", err)
+	}
+	// switch to the alternate screen and hide the cursor
+	fmt.Print("\033[?1049h\033[?25l")
+	defer func() {
+		fmt.Print("\033[?25h\033[?1049l")
+		stty(saved)
+	}()
+
+	// copied codes to clear once expired
+	copied := map[string]time.Time{}
+	defer func() {
+		for code := range copied {
+			clearIfUnchanged(clip, code)
+		}
+	}()
+
+	keys := make(chan string)
+	go readKeys(os.Stdin, keys)
+
+	for {
+		m.render(os.Stdout, terminalHeight())
+
+		now := time.Now()
+		tick := time.After(now.Truncate(time.Second).Add(time.Second).Sub(now))
+		select {
+		case key, ok := <-keys:
+			if !ok {
+				return nil
+			}
+			quit, label := m.handle(key)
+			if quit {
+				return nil
+			}
+			if label != "" {
+				k := m.cfg.Key[label]
+				code, rem, err := getCode(label, k)
+				if err != nil {
+					m.status = "unable to calculate code for " + label
+				} else if err := clip.Write(code); err != nil {
+					m.status = "unable to copy code: " + err.Error()
+				} else {
+					copied[code] = k.expires()
+					m.status = fmt.Sprintf("copied code for %v; clearing in %v seconds", label, rem)
+				}
+			}
+		case <-tick:
+			for code, expires := range copied {
+				if !time.Now().Before(expires) {
+					delete(copied, code)
+					m.status = ""
+					if _, err := clearIfUnchanged(clip, code); err != nil {
+						m.status = "unable to clear clipboard: " + err.Error()
+					}
+				}
+			}
+		}
+	}
+}
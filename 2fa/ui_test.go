@@ -0,0 +1,87 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+var uiCfg = &config{
+	Key: map[string]key{
+		"gh":     key{Issuer: "GitHub", Secret: "MFRGGZDFMZTWQ2LK"},
+		"gitlab": key{Issuer: "GitLab", Secret: "MFRGGZDFMZTWQ2LK"},
+		"aws":    key{Issuer: "Amazon", Secret: "NAR5XTDD3EQU22YU"},
+	},
+}
+
+func press(m *uiModel, keys ...string) (bool, string) {
+	quit, label := false, ""
+	for _, key := range keys {
+		quit, label = m.handle(key)
+	}
+	return quit, label
+}
+
+func TestUINavigation(t *testing.T) {
+	m := newUIModel(uiCfg)
+	if _, label := press(m, "down", "j", "down", "enter"); label != "gitlab" {
+		t.Errorf("Expected to copy gitlab, got %q", label)
+	}
+	if _, label := press(m, "up", "k", "k", "c"); label != "aws" {
+		t.Errorf("Expected to copy aws, got %q", label)
+	}
+	if quit, _ := press(m, "q"); !quit {
+		t.Error("q should quit")
+	}
+}
+
+func TestUISearch(t *testing.T) {
+	m := newUIModel(uiCfg)
+	press(m, "/", "g", "i", "t", "l")
+	if labels := strings.Join(m.visible(), ","); labels != "gitlab" {
+		t.Errorf("Unexpected matches %q", labels)
+	}
+
+	// q is part of the search, not a request to quit
+	if quit, _ := press(m, "q", "backspace", "backspace"); quit {
+		t.Error("Typing q while searching should not quit")
+	}
+	if labels := strings.Join(m.visible(), ","); labels != "gh,gitlab" {
+		t.Errorf("Unexpected matches %q", labels)
+	}
+
+	if _, label := press(m, "enter", "down", "enter"); label != "gitlab" {
+		t.Errorf("Expected to copy gitlab, got %q", label)
+	}
+	press(m, "esc")
+	if len(m.visible()) != 3 {
+		t.Error("esc should clear the search")
+	}
+}
+
+func TestUIReveal(t *testing.T) {
+	m := newUIModel(uiCfg)
+	code, _, err := getCode("gh", uiCfg.Key["gh"])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var screen strings.Builder
+	m.render(&screen, 24)
+	if strings.Contains(screen.String(), code) || !strings.Contains(screen.String(), "******") {
+		t.Errorf("Codes should be hidden by default:\n%v", screen.String())
+	}
+
+	press(m, "r")
+	screen.Reset()
+	m.render(&screen, 24)
+	if !strings.Contains(screen.String(), code) {
+		t.Errorf("Codes should be revealed:\n%v", screen.String())
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	keys := parseKeys([]byte("\x1b[A\x1b[Bq\r\x7f\x03\x1b\x1b[1;5Cé"))
+	if s := strings.Join(keys, " "); s != "up down q enter backspace ctrl-c esc é" {
+		t.Errorf("Unexpected keys %q", s)
+	}
+}
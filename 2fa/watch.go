@@ -0,0 +1,166 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+)
+
+const barWidth = 30
+
+type watchCommand struct {
+	timeout time.Duration
+	group   string
+	tags    tagsFlag
+}
+
+func (c *watchCommand) Name() string {
+	return "watch"
+}
+
+func (c *watchCommand) FlagSet() *flag.FlagSet {
+	fs := newFlagSet(c.Name())
+	fs.DurationVar(&c.timeout, "timeout", 0, "")
+	fs.StringVar(&c.group, "group", "", "")
+	c.tags = nil
+	fs.Var(&c.tags, "tag", "")
+	return fs
+}
+
+func (c *watchCommand) Run(args []string) error {
+	fs := c.FlagSet()
+	if err := fs.Parse(args); err != nil {
+		return errUsage("%v", err)
+	}
+
+	if fs.NArg() != 0 {
+		return errUsage("watch takes no arguments")
+	}
+	cfg, err := loadCfg()
+	if err != nil {
+		return err
+	}
+	labels := cfg.filter(c.group, c.tags)
+	if len(labels) == 0 {
+		return matchError{"no keys match the group and tags"}
+	}
+	return watch(cfg, labels, c.timeout)
+}
+
+func (c *watchCommand) Usage() {
+	usage := "    watch       show live codes for all keys"
+	fmt.Println(usage)
+}
+
+func (c *watchCommand) Help() {
+	help := "\n" + c.Name() + " usage:\n\n    2fa " + c.Name() + " [-timeout duration] [-group group] [-tag tag]...\n\n"
+	help += "    Shows codes for all keys stored in " + getCfgPath() + ", refreshing as each expires.\n"
+	help += "    Press Ctrl-C to exit.\n\n"
+	help += "    -timeout    stop watching after a duration, such as 90s or 5m\n"
+	help += "    -group      only show keys in the group or a folder beneath it\n"
+	help += "    -tag        only show keys with the tag; may be repeated\n"
+	fmt.Println(help)
+}
+
+// watch redraws the codes for the labeled keys once a second until
+// interrupted or, if positive, the timeout elapses. With structured output,
+// a record is written for each new code instead.
+func watch(cfg *config, labels []string, timeout time.Duration) error {
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		expired = time.After(timeout)
+	}
+
+	draw := streamer(cfg, labels)
+	if !structured() {
+		// hide the cursor while redrawing
+		fmt.Fprint(stdout, "\033[?25l")
+		defer fmt.Fprint(stdout, "\033[?25h")
+		draw = redrawer(cfg, labels)
+	}
+
+	for {
+		if err := draw(); err != nil {
+			return err
+		}
+
+		// periods are whole seconds, so waking on each second
+		// boundary picks up every rollover as it happens
+		now := time.Now()
+		tick := time.After(now.Truncate(time.Second).Add(time.Second).Sub(now))
+		select {
+		case <-interrupt:
+			return nil
+		case <-expired:
+			return nil
+		case <-tick:
+		}
+	}
+}
+
+// redrawer returns a function that draws a line per key, overwriting the
+// lines drawn by its previous call.
+func redrawer(cfg *config, labels []string) func() error {
+	width := 0
+	for _, label := range labels {
+		if len(label) > width {
+			width = len(label)
+		}
+	}
+
+	drawn := false
+	return func() error {
+		if drawn {
+			fmt.Fprintf(stdout, "\033[%dA", len(labels))
+		}
+		for _, label := range labels {
+			fmt.Fprint(stdout, "\033[2K"+watchLine(label, cfg.Key[label], width, false)+"\n")
+		}
+		drawn = true
+		return nil
+	}
+}
+
+// streamer returns a function that emits a record for each key whose code
+// has changed since its previous call.
+func streamer(cfg *config, labels []string) func() error {
+	e := newEmitter()
+	last := map[string]string{}
+	return func() error {
+		for _, label := range labels {
+			r, err := newKeyRecord(label, cfg.Key[label]).withCode(cfg.Key[label])
+			if err != nil {
+				return err
+			}
+			if last[label] != r.Code {
+				if err := e.emit(r); err != nil {
+					return err
+				}
+				last[label] = r.Code
+			}
+		}
+		return nil
+	}
+}
+
+// watchLine formats a key's code and time remaining, masking the code if
+// hidden.
+func watchLine(label string, k key, width int, hidden bool) string {
+	code, rem, err := getCode(label, k)
+	if err != nil {
+		return fmt.Sprintf("%-*v  calculation failed", width, label)
+	}
+	if hidden {
+		code = strings.Repeat("*", len(code))
+	}
+	filled := int(rem * barWidth / k.period())
+	bar := strings.Repeat("#", filled) + strings.Repeat("-", barWidth-filled)
+	return fmt.Sprintf("%-*v  %v  [%v] %2ds  %v", width, label, code, bar, rem, k.Issuer)
+}
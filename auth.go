@@ -1,12 +1,7 @@
 package otp
 
 import (
-	"bytes"
-	"crypto/hmac"
-	"encoding/base32"
-	"encoding/binary"
 	"hash"
-	"strconv"
 	"time"
 )
 
@@ -27,33 +22,15 @@ func GetInterval(period int64) (int64, int64) {
 // The h parameter is a hash function to use in the HMAC.
 // The digits parameter is the length of returned code.
 //
+// A Generator calculates many codes for one key without the cost of
+// decoding its secret each time.
+//
 // Example:
 //      code, err := GetCode("MFRGGZDFMZTWQ2LK", 1, sha1.New, 6)
 func GetCode(secret32 string, iv int64, h Hash, digits int) (string, error) {
-	key, err := base32.StdEncoding.DecodeString(secret32)
+	g, err := NewGenerator(secret32, h, digits)
 	if err != nil {
 		return "", err
 	}
-
-	msg := bytes.Buffer{}
-	binary.Write(&msg, binary.BigEndian, iv)
-
-	mac := hmac.New(h, key)
-	mac.Write(msg.Bytes())
-	digest := mac.Sum(nil)
-
-	offset := digest[len(digest)-1] & 0xF
-	trunc := digest[offset : offset+4]
-
-	var code int32
-	truncBytes := bytes.NewBuffer(trunc)
-	_ = binary.Read(truncBytes, binary.BigEndian, &code)
-
-	code = (code & 0x7FFFFFFF) % 1000000
-
-	stringCode := strconv.Itoa(int(code))
-	for len(stringCode) < digits {
-		stringCode = "0" + stringCode
-	}
-	return stringCode, nil
+	return g.Code(iv), nil
 }
@@ -0,0 +1,200 @@
+package otp
+
+import (
+	"crypto/subtle"
+	"errors"
+	"time"
+)
+
+// ErrReplayedCode is returned by Verify for a code that was valid but has
+// already been accepted.
+var ErrReplayedCode = errors.New("code has already been used")
+
+// Authenticator verifies the codes of users whose keys are kept in a
+// KeyStore. Each verification loads, checks and saves a user's record while
+// holding a lock for that user, so concurrent attempts can't both accept a
+// code or lose an update.
+type Authenticator struct {
+	Store     KeyStore
+	Window    int              // Steps either side of now accepted for totp, to allow for clock drift.
+	LookAhead int              // Counters past the expected one accepted for hotp, to allow for skipped codes.
+	Now       func() time.Time // Clock used for totp. Defaults to time.Now.
+	Throttle  *Throttle        // Optional limit on failed attempts per user.
+	Drift     *DriftTracker    // Optional recentring of the totp window on each user's clock drift.
+
+	users keyLocks
+}
+
+// NewAuthenticator returns an authenticator using the store, accepting
+// codes one step either side of now for totp and up to 10 counters ahead for
+// hotp.
+func NewAuthenticator(store KeyStore) *Authenticator {
+	return &Authenticator{
+		Store:     store,
+		Window:    1,
+		LookAhead: 10,
+		Now:       time.Now,
+	}
+}
+
+// now returns the current time from the authenticator's clock.
+func (a *Authenticator) now() time.Time {
+	if a.Now == nil {
+		return time.Now()
+	}
+	return a.Now()
+}
+
+// Enroll validates the key and stores it for the user, replacing any
+// existing record.
+func (a *Authenticator) Enroll(user string, k Key) error {
+	if err := k.Validate(); err != nil {
+		return err
+	}
+	defer a.users.lock(user)()
+	return a.Store.Save(user, Record{Key: k})
+}
+
+// Remove deletes the user's record.
+func (a *Authenticator) Remove(user string) error {
+	defer a.users.lock(user)()
+	return a.Store.Delete(user)
+}
+
+// Key returns the user's key.
+func (a *Authenticator) Key(user string) (Key, error) {
+	defer a.users.lock(user)()
+	r, err := a.Store.Load(user)
+	return r.Key, err
+}
+
+// Verify reports whether the code is valid for the user. An accepted totp
+// step or hotp counter is saved, so the same code is never accepted twice;
+// a repeated code returns ErrReplayedCode. Failures are counted in the
+// user's record and reset by a success. With a Throttle, a *ThrottledError
+// is returned without checking the code while the user is backing off.
+func (a *Authenticator) Verify(user, code string) (bool, error) {
+	if a.Throttle != nil {
+		return a.Throttle.Verify(user, func() (bool, error) {
+			return a.verify(user, code)
+		})
+	}
+	return a.verify(user, code)
+}
+
+// verify checks the code and saves the user's updated record.
+func (a *Authenticator) verify(user, code string) (bool, error) {
+	defer a.users.lock(user)()
+
+	r, err := a.Store.Load(user)
+	if err != nil {
+		return false, err
+	}
+
+	var ok bool
+	switch r.Key.Method {
+	case "totp":
+		ok, err = a.verifyTOTP(&r, code)
+	case "hotp":
+		ok, err = a.verifyHOTP(&r, code)
+	default:
+		err = ErrInvalidMethod
+	}
+	if err != nil && err != ErrReplayedCode {
+		return false, err
+	}
+
+	if ok {
+		r.Failures = 0
+	} else {
+		r.Failures++
+	}
+	if serr := a.Store.Save(user, r); serr != nil {
+		return false, serr
+	}
+	return ok, err
+}
+
+// verifyTOTP checks the code against the steps around now, or around the
+// user's estimated drift with a DriftTracker, recording the step it matches.
+func (a *Authenticator) verifyTOTP(r *Record, code string) (bool, error) {
+	offset := 0
+	if a.Drift != nil {
+		offset = a.Drift.Offset(*r)
+	}
+	s, step, ok, err := matchWindow(r.Key, code, a.now(), a.Window, offset)
+	if err != nil || !ok {
+		return false, err
+	}
+	if s <= r.LastStep {
+		return false, ErrReplayedCode
+	}
+	r.LastStep = s
+	if a.Drift != nil {
+		a.Drift.Observe(r, int(s-step))
+	}
+	return true, nil
+}
+
+// verifyHOTP checks the code against the expected counter and those after
+// it, moving the counter past the one it matches.
+func (a *Authenticator) verifyHOTP(r *Record, code string) (bool, error) {
+	c, _, ok, err := matchWindow(r.Key, code, time.Time{}, a.LookAhead, 0)
+	if err != nil {
+		return false, err
+	}
+	if ok {
+		r.Key.Counter = int(c) + 1
+		return true, nil
+	}
+
+	// a code from before the expected counter was accepted earlier
+	for c := r.Key.Counter - 1; c >= 0 && c >= r.Key.Counter-a.LookAhead; c-- {
+		if match, _ := codeMatches(r.Key, int64(c), code); match {
+			return false, ErrReplayedCode
+		}
+	}
+	return false, nil
+}
+
+// matchWindow finds the step or counter at which the key gives the code.
+// For totp it looks window steps either side of the step at t, moved by
+// offset steps, and also returns the step at t for measuring drift; for
+// hotp it looks from the key's counter to window counters past it.
+func matchWindow(k Key, code string, t time.Time, window, offset int) (iv, step int64, ok bool, err error) {
+	var first, last int64
+	switch k.Method {
+	case "totp":
+		if k.Period < 1 {
+			return 0, 0, false, ErrInvalidPeriod
+		}
+		step = t.Unix() / int64(k.Period)
+		center := step + int64(offset)
+		first, last = center-int64(window), center+int64(window)
+	case "hotp":
+		first, last = int64(k.Counter), int64(k.Counter)+int64(window)
+	default:
+		return 0, 0, false, ErrInvalidMethod
+	}
+
+	for iv := first; iv <= last; iv++ {
+		match, err := codeMatches(k, iv, code)
+		if err != nil {
+			return 0, 0, false, err
+		}
+		if match {
+			return iv, step, true, nil
+		}
+	}
+	return 0, step, false, nil
+}
+
+// codeMatches compares the code for the initial value with the given one in
+// constant time.
+func codeMatches(k Key, iv int64, code string) (bool, error) {
+	want, err := k.GetCode(iv)
+	if err != nil {
+		return false, err
+	}
+	return subtle.ConstantTimeCompare([]byte(want), []byte(code)) == 1, nil
+}
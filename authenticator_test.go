@@ -0,0 +1,131 @@
+package otp
+
+import (
+	"crypto/sha1"
+	"sync"
+	"testing"
+	"time"
+)
+
+var authNow = time.Unix(1433160000, 0)
+
+func newTestAuthenticator(t *testing.T, k Key) *Authenticator {
+	a := NewAuthenticator(NewMemoryStore())
+	a.Now = func() time.Time { return authNow }
+	if err := a.Enroll("alice", k); err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func totpKey() Key {
+	return Key{Method: "totp", Label: "alice", Secret32: "MFRGGZDFMZTWQ2LK", Algo: sha1.New, Digits: 6, Period: 30}
+}
+
+func hotpKey() Key {
+	return Key{Method: "hotp", Label: "alice", Secret32: "MFRGGZDFMZTWQ2LK", Algo: sha1.New, Digits: 6, Counter: 5}
+}
+
+func mustCode(t *testing.T, k Key, iv int64) string {
+	code, err := k.GetCode(iv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+func TestAuthenticatorTOTP(t *testing.T) {
+	k := totpKey()
+	a := newTestAuthenticator(t, k)
+	step := authNow.Unix() / 30
+
+	if ok, err := a.Verify("alice", mustCode(t, k, step-1)); !ok || err != nil {
+		t.Errorf("Expected the previous step to be accepted, got %v %v", ok, err)
+	}
+	if ok, err := a.Verify("alice", mustCode(t, k, step)); !ok || err != nil {
+		t.Errorf("Expected the current step to be accepted, got %v %v", ok, err)
+	}
+	if ok, err := a.Verify("alice", mustCode(t, k, step)); ok || err != ErrReplayedCode {
+		t.Errorf("Expected a replay to be rejected, got %v %v", ok, err)
+	}
+	if ok, err := a.Verify("alice", mustCode(t, k, step-1)); ok || err != ErrReplayedCode {
+		t.Errorf("Expected an older step to be rejected, got %v %v", ok, err)
+	}
+	if ok, err := a.Verify("alice", mustCode(t, k, step+2)); ok || err != nil {
+		t.Errorf("Expected a step outside the window to fail, got %v %v", ok, err)
+	}
+
+	r, _ := a.Store.Load("alice")
+	if r.LastStep != step || r.Failures != 3 {
+		t.Errorf("Unexpected record %+v", r)
+	}
+
+	if ok, _ := a.Verify("alice", mustCode(t, k, step+1)); !ok {
+		t.Error("Expected the next step to be accepted")
+	}
+	if r, _ := a.Store.Load("alice"); r.Failures != 0 {
+		t.Errorf("Expected a success to reset failures, got %v", r.Failures)
+	}
+}
+
+func TestAuthenticatorHOTP(t *testing.T) {
+	k := hotpKey()
+	a := newTestAuthenticator(t, k)
+
+	if ok, err := a.Verify("alice", mustCode(t, k, 8)); !ok || err != nil {
+		t.Errorf("Expected a code within the look-ahead to be accepted, got %v %v", ok, err)
+	}
+	if got, _ := a.Key("alice"); got.Counter != 9 {
+		t.Errorf("Expected the counter to move to 9, got %v", got.Counter)
+	}
+	if ok, err := a.Verify("alice", mustCode(t, k, 8)); ok || err != ErrReplayedCode {
+		t.Errorf("Expected a replay to be rejected, got %v %v", ok, err)
+	}
+	if ok, err := a.Verify("alice", mustCode(t, k, 30)); ok || err != nil {
+		t.Errorf("Expected a code past the look-ahead to fail, got %v %v", ok, err)
+	}
+}
+
+func TestAuthenticatorErrors(t *testing.T) {
+	a := NewAuthenticator(NewMemoryStore())
+	if _, err := a.Verify("nobody", "123456"); err != ErrUnknownUser {
+		t.Errorf("Expected ErrUnknownUser, got %v", err)
+	}
+	k := totpKey()
+	k.Secret32 = "abc123"
+	if err := a.Enroll("alice", k); err == nil {
+		t.Error("Expected an invalid key to be refused")
+	}
+	if err := a.Remove("nobody"); err != nil {
+		t.Errorf("Removing a missing user failed: %v", err)
+	}
+}
+
+func TestAuthenticatorConcurrent(t *testing.T) {
+	k := totpKey()
+	a := newTestAuthenticator(t, k)
+	code := mustCode(t, k, authNow.Unix()/30)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	accepted := 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if ok, _ := a.Verify("alice", code); ok {
+				mu.Lock()
+				accepted++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if accepted != 1 {
+		t.Errorf("Expected the code to be accepted once, got %v", accepted)
+	}
+	if len(a.users.locks) != 0 {
+		t.Errorf("Expected user locks to be released, got %v", a.users.locks)
+	}
+}
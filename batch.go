@@ -0,0 +1,102 @@
+package otp
+
+import (
+	"context"
+	"runtime"
+	"sync"
+)
+
+// BatchResult is a code calculated by Batch.
+type BatchResult struct {
+	Index int    // Position of the key in the slice given to Batch.
+	Step  int64  // Time step or counter the code is for.
+	Code  string // The code, unless Err is set.
+	Err   error  // Why the key's codes couldn't be calculated; sent once per key.
+}
+
+// Batch calculates the codes of each key for the steps from first to last,
+// inclusive, on up to workers goroutines; workers < 1 uses one per CPU.
+// Results are sent on the returned channel as they are ready, in step order
+// for each key but interleaved across keys, and the channel is closed when
+// all are sent or ctx is done. Callers must drain the channel or cancel ctx
+// to let the workers exit.
+//
+// Example:
+//      from, _ := GetInterval(30)
+//      for r := range Batch(ctx, keys, from-10, from+10, 0) {
+//          ...
+//      }
+func Batch(ctx context.Context, keys []Key, first, last int64, workers int) <-chan BatchResult {
+	if workers < 1 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers > len(keys) {
+		workers = len(keys)
+	}
+
+	results := make(chan BatchResult, workers)
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+
+	go func() {
+		defer close(jobs)
+		for i := range keys {
+			select {
+			case jobs <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if !batchKey(ctx, results, i, keys[i], first, last) {
+					return
+				}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+	return results
+}
+
+// batchKey sends the key's codes for the steps, reporting false if ctx was
+// done first.
+func batchKey(ctx context.Context, results chan<- BatchResult, i int, k Key, first, last int64) bool {
+	send := func(r BatchResult) bool {
+		if ctx.Err() != nil {
+			return false
+		}
+		select {
+		case results <- r:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	g, err := k.Generator()
+	if err != nil {
+		return send(BatchResult{Index: i, Step: first, Err: err})
+	}
+	buf := make([]byte, 0, k.Digits)
+	for step := first; step <= last; step++ {
+		buf = g.AppendCode(buf[:0], step)
+		if !send(BatchResult{Index: i, Step: step, Code: string(buf)}) {
+			return false
+		}
+		// step++ would wrap around rather than pass math.MaxInt64
+		if step == last {
+			break
+		}
+	}
+	return true
+}
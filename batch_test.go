@@ -0,0 +1,97 @@
+package otp
+
+import (
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"math"
+	"testing"
+)
+
+var batchKeys = []Key{
+	Key{Secret32: "MFRGGZDFMZTWQ2LK", Algo: sha1.New, Digits: 6},
+	Key{Secret32: "NAR5XTDD3EQU22YU", Algo: sha256.New, Digits: 8},
+	Key{Secret32: "abc123", Algo: sha1.New, Digits: 6},
+	Key{Secret32: rfcSecret, Algo: sha1.New, Digits: 6},
+}
+
+func TestBatch(t *testing.T) {
+	for _, workers := range []int{0, 1, 3, 100} {
+		got := map[int][]BatchResult{}
+		for r := range Batch(context.Background(), batchKeys, 100, 109, workers) {
+			got[r.Index] = append(got[r.Index], r)
+		}
+
+		if len(got[2]) != 1 || got[2][0].Err == nil {
+			t.Errorf("Expected one error for the bad key, got %v", got[2])
+		}
+		for _, i := range []int{0, 1, 3} {
+			k := batchKeys[i]
+			if len(got[i]) != 10 {
+				t.Fatalf("Expected 10 codes for key %v, got %v", i, len(got[i]))
+			}
+			for j, r := range got[i] {
+				want, _ := GetCode(k.Secret32, 100+int64(j), k.Algo, k.Digits)
+				if r.Step != 100+int64(j) || r.Code != want || r.Err != nil {
+					t.Errorf("Expected %v at step %v for key %v, got %+v", want, 100+j, i, r)
+				}
+			}
+		}
+	}
+}
+
+func TestBatchCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	results := Batch(ctx, batchKeys, 0, 1<<40, 2)
+
+	n := 0
+	for range results {
+		n++
+		if n == 50 {
+			cancel()
+		}
+	}
+	// the buffered results and those being sent may still arrive
+	if n > 50+2*2 {
+		t.Errorf("Expected results to stop soon after cancelling, got %v", n)
+	}
+}
+
+func TestBatchEmpty(t *testing.T) {
+	for range Batch(context.Background(), nil, 0, 10, 4) {
+		t.Error("Expected no results without keys")
+	}
+	for range Batch(context.Background(), batchKeys[:1], 10, 0, 4) {
+		t.Error("Expected no results for an empty step range")
+	}
+}
+
+func TestBatchLastStep(t *testing.T) {
+	n := 0
+	for r := range Batch(context.Background(), batchKeys[:1], math.MaxInt64-2, math.MaxInt64, 1) {
+		if r.Step != math.MaxInt64-2+int64(n) {
+			t.Errorf("Unexpected step %v", r.Step)
+		}
+		n++
+		if n > 3 {
+			t.Fatal("Expected the steps to stop at math.MaxInt64")
+		}
+	}
+	if n != 3 {
+		t.Errorf("Expected 3 codes, got %v", n)
+	}
+}
+
+func BenchmarkBatch(b *testing.B) {
+	keys := make([]Key, 1000)
+	for i := range keys {
+		keys[i] = batchKeys[i%2]
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for range Batch(context.Background(), keys, 0, 99, 0) {
+		}
+	}
+}
@@ -0,0 +1,170 @@
+/*
+Package bitwarden reads and writes the one-time passwords of logins in
+Bitwarden's unencrypted JSON export.
+
+A login's totp field holds an otpauth URI, a bare Base32 secret for a
+6-digit SHA-1 key with a 30 second period, or a steam:// secret.
+*/
+package bitwarden
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/tristanwietsma/otp"
+	"github.com/tristanwietsma/otp/internal/otpauth"
+	"github.com/tristanwietsma/otp/internal/uuid"
+	"io"
+	"strings"
+)
+
+// ErrEncrypted is returned by ReadJSON for an encrypted export.
+var ErrEncrypted = errors.New("export is encrypted; export it again as unencrypted JSON")
+
+// typeLogin is the item type of logins.
+const typeLogin = 1
+
+// Item is a Bitwarden login with a one-time password.
+type Item struct {
+	Folder   string  // Name of the item's folder; slashes nest folders.
+	Name     string  // Name of the item.
+	Username string  // Username of the login.
+	Key      otp.Key // The login's key, labeled with the item's name.
+	Err      error   // Why the key couldn't be read, if it couldn't.
+}
+
+// export is the JSON export. Only the fields read or written are kept.
+type export struct {
+	Encrypted bool         `json:"encrypted"`
+	Folders   []folder     `json:"folders"`
+	Items     []exportItem `json:"items"`
+}
+
+type folder struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type exportItem struct {
+	Type     int     `json:"type"`
+	FolderID *string `json:"folderId"`
+	Name     string  `json:"name"`
+	Notes    *string `json:"notes"`
+	Favorite bool    `json:"favorite"`
+	Login    *login  `json:"login,omitempty"`
+}
+
+type login struct {
+	URIs     []interface{} `json:"uris"`
+	Username *string       `json:"username"`
+	Password *string       `json:"password"`
+	TOTP     *string       `json:"totp"`
+}
+
+// ParseTOTP returns the key in a login's totp field.
+func ParseTOTP(totp string) (otp.Key, error) {
+	totp = strings.TrimSpace(totp)
+	switch {
+	case strings.HasPrefix(totp, "otpauth://"):
+		return otpauth.Parse(totp)
+	case strings.HasPrefix(totp, "steam://"):
+		return otp.Key{}, otpauth.ErrSteam
+	}
+	secret, err := otp.SecretFromBase32(totp)
+	if err != nil {
+		return otp.Key{}, err
+	}
+	k := otp.Key{Method: "totp", Algo: sha1.New, Digits: 6, Period: 30}
+	k.SetSecret(secret)
+	return k, nil
+}
+
+// FormatTOTP returns the key as an otpauth URI for a login's totp field.
+func FormatTOTP(k otp.Key) (string, error) {
+	if err := k.Validate(); err != nil {
+		return "", err
+	}
+	if k.Method != "totp" {
+		return "", errors.New("Bitwarden only supports totp")
+	}
+	return otpauth.URI(k), nil
+}
+
+// ReadJSON returns the logins with one-time passwords in an unencrypted
+// JSON export. A login whose key can't be read is returned with Err set.
+func ReadJSON(r io.Reader) ([]Item, error) {
+	var ex export
+	if err := json.NewDecoder(r).Decode(&ex); err != nil {
+		return nil, err
+	}
+	if ex.Encrypted {
+		return nil, ErrEncrypted
+	}
+
+	folders := map[string]string{}
+	for _, f := range ex.Folders {
+		folders[f.ID] = f.Name
+	}
+
+	items := []Item{}
+	for _, ei := range ex.Items {
+		if ei.Type != typeLogin || ei.Login == nil || ei.Login.TOTP == nil || strings.TrimSpace(*ei.Login.TOTP) == "" {
+			continue
+		}
+		it := Item{Name: ei.Name}
+		if ei.FolderID != nil {
+			it.Folder = folders[*ei.FolderID]
+		}
+		if ei.Login.Username != nil {
+			it.Username = *ei.Login.Username
+		}
+
+		it.Key, it.Err = ParseTOTP(*ei.Login.TOTP)
+		if it.Err == nil {
+			it.Key.Label = it.Name
+			it.Err = it.Key.Validate()
+		}
+		items = append(items, it)
+	}
+	return items, nil
+}
+
+// WriteJSON writes the items as an unencrypted JSON export for Bitwarden's
+// importer, creating their folders.
+func WriteJSON(w io.Writer, items []Item) error {
+	ex := export{Folders: []folder{}, Items: []exportItem{}}
+	folderIDs := map[string]string{}
+
+	for _, it := range items {
+		totp, err := FormatTOTP(it.Key)
+		if err != nil {
+			return fmt.Errorf("%v: %w", it.Name, err)
+		}
+		ei := exportItem{Type: typeLogin, Name: it.Name, Login: &login{URIs: []interface{}{}, TOTP: &totp}}
+		if it.Username != "" {
+			username := it.Username
+			ei.Login.Username = &username
+		}
+
+		if name := strings.Trim(it.Folder, "/"); name != "" {
+			id, ok := folderIDs[name]
+			if !ok {
+				u, err := uuid.New()
+				if err != nil {
+					return err
+				}
+				id = u.String()
+				folderIDs[name] = id
+				ex.Folders = append(ex.Folders, folder{id, name})
+			}
+			ei.FolderID = &id
+		}
+		ex.Items = append(ex.Items, ei)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(ex)
+}
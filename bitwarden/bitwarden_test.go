@@ -0,0 +1,110 @@
+package bitwarden
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"github.com/tristanwietsma/otp"
+	"github.com/tristanwietsma/otp/internal/otpauth"
+	"os"
+	"strings"
+	"testing"
+)
+
+func readFixture(t *testing.T) []Item {
+	f, err := os.Open("testdata/export.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	items, err := ReadJSON(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return items
+}
+
+func TestReadJSON(t *testing.T) {
+	items := readFixture(t)
+	if len(items) != 3 {
+		t.Fatalf("Expected the 3 logins with totp, got %+v", items)
+	}
+
+	if it := items[0]; it.Name != "GitHub" || it.Username != "alice" || it.Folder != "" || it.Err != nil ||
+		it.Key.Label != "GitHub" || it.Key.Issuer != "GitHub" || it.Key.Secret32 != "MFRGGZDFMZTWQ2LK" {
+		t.Errorf("Unexpected item %+v", it)
+	}
+	if it := items[1]; it.Name != "Amazon" || it.Folder != "Work/AWS" || it.Err != nil ||
+		it.Key.Secret32 != "NAR5XTDD3EQU22YU" || it.Key.Digits != 6 || it.Key.Period != 30 {
+		t.Errorf("Unexpected item %+v", it)
+	}
+	if it := items[2]; it.Name != "Steam" || !errors.Is(it.Err, otpauth.ErrSteam) {
+		t.Errorf("Expected the Steam login to fail, got %+v", it)
+	}
+}
+
+func TestReadJSONErrors(t *testing.T) {
+	if _, err := ReadJSON(strings.NewReader(`{"encrypted": true, "encKeyValidation_DO_NOT_EDIT": "x"}`)); err != ErrEncrypted {
+		t.Errorf("Expected ErrEncrypted, got %v", err)
+	}
+	if _, err := ReadJSON(strings.NewReader(`{"items": [`)); err == nil {
+		t.Error("Expected an error for truncated JSON")
+	}
+}
+
+func TestParseTOTP(t *testing.T) {
+	k, err := ParseTOTP("otpauth://totp/x?secret=MFRGGZDFMZTWQ2LK&algorithm=SHA256&digits=8")
+	if err != nil || k.Digits != 8 || otpauth.Algorithm(k.Algo) != "SHA256" {
+		t.Errorf("Unexpected key %+v %v", k, err)
+	}
+	if _, err := ParseTOTP("not a secret!"); !errors.Is(err, otp.ErrInvalidSecret) {
+		t.Errorf("Expected ErrInvalidSecret, got %v", err)
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	items := readFixture(t)[:2]
+	items = append(items, Item{Folder: "Work/AWS", Name: "Console", Key: otp.Key{
+		Method: "totp", Label: "Console", Secret32: "MFRGGZDFMZTWQ2LK", Algo: sha256.New, Digits: 8, Period: 60,
+	}})
+
+	var buf bytes.Buffer
+	if err := WriteJSON(&buf, items); err != nil {
+		t.Fatal(err)
+	}
+
+	var ex struct {
+		Folders []folder
+		Items   []map[string]interface{}
+	}
+	if err := json.Unmarshal(buf.Bytes(), &ex); err != nil {
+		t.Fatal(err)
+	}
+	if len(ex.Folders) != 1 || ex.Folders[0].Name != "Work/AWS" || len(ex.Folders[0].ID) != 36 {
+		t.Errorf("Expected one folder, got %+v", ex.Folders)
+	}
+	if ex.Items[0]["folderId"] != nil || ex.Items[1]["folderId"] != ex.Folders[0].ID || ex.Items[2]["folderId"] != ex.Folders[0].ID {
+		t.Errorf("Unexpected folders %v", ex.Items)
+	}
+
+	back, err := ReadJSON(&buf)
+	if err != nil || len(back) != 3 {
+		t.Fatalf("Expected the items back, got %+v %v", back, err)
+	}
+	for i := range back {
+		if back[i].Folder != items[i].Folder || back[i].Username != items[i].Username || back[i].Key.ToURI() != items[i].Key.ToURI() {
+			t.Errorf("Expected %+v back, got %+v", items[i], back[i])
+		}
+	}
+}
+
+func TestWriteJSONErrors(t *testing.T) {
+	hotp := otp.Key{Method: "hotp", Label: "h", Secret32: "MFRGGZDFMZTWQ2LK", Algo: sha1.New, Digits: 6}
+	for _, k := range []otp.Key{hotp, {Method: "totp", Label: "bad"}} {
+		if err := WriteJSON(&bytes.Buffer{}, []Item{{Name: k.Label, Key: k}}); err == nil || !strings.HasPrefix(err.Error(), k.Label+": ") {
+			t.Errorf("Expected %v to be refused, got %v", k.Label, err)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package otp
+
+import "math"
+
+// DriftTracker follows how far each user's totp clock has drifted from the
+// server's. Every accepted code moves a smoothed estimate, kept in the
+// user's Record, towards the step offset it matched, and the verification
+// window is centred on the estimate rounded to whole steps. A token running
+// slowly for months is then still accepted without widening the window.
+type DriftTracker struct {
+	Weight   float64 // Weight, from 0 to 1, given to each new offset in the estimate.
+	MaxDrift int     // Furthest, in steps, the window is moved from now.
+}
+
+// NewDriftTracker returns a tracker that gives each offset a quarter of the
+// weight and moves the window at most 10 steps, 5 minutes at the usual
+// period.
+func NewDriftTracker() *DriftTracker {
+	return &DriftTracker{Weight: 0.25, MaxDrift: 10}
+}
+
+// Offset returns the step offset to centre the user's window on.
+func (d *DriftTracker) Offset(r Record) int {
+	return int(d.clamp(math.Round(r.Drift)))
+}
+
+// Observe moves the record's estimate towards the offset of a step that
+// was accepted, relative to the current step.
+func (d *DriftTracker) Observe(r *Record, offset int) {
+	r.Drift = d.clamp(r.Drift + d.Weight*(float64(offset)-r.Drift))
+}
+
+// clamp limits drift to MaxDrift steps either way.
+func (d *DriftTracker) clamp(drift float64) float64 {
+	max := float64(d.MaxDrift)
+	return math.Max(-max, math.Min(max, drift))
+}
@@ -0,0 +1,61 @@
+package otp
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDriftTracker(t *testing.T) {
+	d := NewDriftTracker()
+	r := Record{}
+
+	for i := 0; i < 2; i++ {
+		d.Observe(&r, -1)
+	}
+	if r.Drift != -0.4375 || d.Offset(r) != 0 {
+		t.Errorf("Expected drift -0.4375 and no offset yet, got %v %v", r.Drift, d.Offset(r))
+	}
+	d.Observe(&r, -1)
+	if d.Offset(r) != -1 {
+		t.Errorf("Expected an offset of -1, got %v", d.Offset(r))
+	}
+
+	for i := 0; i < 100; i++ {
+		d.Observe(&r, 50)
+	}
+	if r.Drift != 10 || d.Offset(r) != 10 {
+		t.Errorf("Expected drift to be limited to 10 steps, got %v %v", r.Drift, d.Offset(r))
+	}
+}
+
+func TestAuthenticatorDrift(t *testing.T) {
+	k := totpKey()
+	a := newTestAuthenticator(t, k)
+	a.Drift = NewDriftTracker()
+	now := authNow
+	a.Now = func() time.Time { return now }
+
+	// a token one step slow is accepted at the edge of the window, which
+	// moves the estimate until the window is centred on it
+	for i := 0; i < 3; i++ {
+		step := now.Unix() / 30
+		if ok, err := a.Verify("alice", mustCode(t, k, step-1)); !ok || err != nil {
+			t.Fatalf("Expected the previous step to be accepted, got %v %v", ok, err)
+		}
+		now = now.Add(30 * time.Second)
+	}
+	r, _ := a.Store.Load("alice")
+	if a.Drift.Offset(r) != -1 {
+		t.Fatalf("Expected the window to move a step back, got drift %v", r.Drift)
+	}
+
+	step := now.Unix() / 30
+	if ok, err := a.Verify("alice", mustCode(t, k, step+1)); ok || err != nil {
+		t.Errorf("Expected the next step to fall outside the moved window, got %v %v", ok, err)
+	}
+	now = now.Add(30 * time.Second)
+	step++
+	if ok, err := a.Verify("alice", mustCode(t, k, step-2)); !ok || err != nil {
+		t.Errorf("Expected a token two steps slow to be accepted, got %v %v", ok, err)
+	}
+}
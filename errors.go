@@ -0,0 +1,93 @@
+package otp
+
+import (
+	"errors"
+	"strings"
+)
+
+// Errors reported for the fields of a key. Use errors.Is to test for them
+// in the error returned by Validate, FromURI or the constructors.
+var (
+	ErrInvalidURI     = errors.New("invalid otpauth URI")
+	ErrInvalidMethod  = errors.New("method is not totp or hotp")
+	ErrMissingLabel   = errors.New("missing label")
+	ErrMissingSecret  = errors.New("missing secret")
+	ErrInvalidSecret  = errors.New("secret is not valid Base32")
+	ErrInvalidAlgo    = errors.New("unsupported hashing algorithm")
+	ErrInvalidDigits  = errors.New("digits is not 6 or 8")
+	ErrInvalidPeriod  = errors.New("period is not a positive integer")
+	ErrInvalidCounter = errors.New("counter is not an integer")
+)
+
+// FieldError reports a key field that failed validation.
+type FieldError struct {
+	Field string // Name of the Key field, or "URI" for the URI as a whole.
+	Err   error  // One of the Err values above, possibly wrapped.
+}
+
+func (e FieldError) Error() string {
+	return e.Err.Error()
+}
+
+func (e FieldError) Unwrap() error {
+	return e.Err
+}
+
+// ValidationError lists every field of a key that failed validation.
+//
+// Example:
+//      var verr *ValidationError
+//      if errors.As(err, &verr) {
+//          for _, f := range verr.Fields {
+//              fmt.Println(f.Field, f.Err)
+//          }
+//      }
+//      if errors.Is(err, ErrInvalidSecret) {
+//          ...
+//      }
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := []string{}
+	for _, f := range e.Fields {
+		msgs = append(msgs, f.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Unwrap returns the field errors, so errors.Is and errors.As look
+// through each of them.
+func (e *ValidationError) Unwrap() []error {
+	errs := []error{}
+	for _, f := range e.Fields {
+		errs = append(errs, f)
+	}
+	return errs
+}
+
+// add records a failing field, ignoring a nil err.
+func (e *ValidationError) add(field string, err error) {
+	if err != nil {
+		e.Fields = append(e.Fields, FieldError{field, err})
+	}
+}
+
+// has reports whether the field has failed.
+func (e *ValidationError) has(field string) bool {
+	for _, f := range e.Fields {
+		if f.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+// err returns e as an error, or nil if no field failed.
+func (e *ValidationError) err() error {
+	if len(e.Fields) == 0 {
+		return nil
+	}
+	return e
+}
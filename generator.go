@@ -0,0 +1,98 @@
+package otp
+
+import (
+	"crypto/hmac"
+	"encoding/binary"
+	"hash"
+)
+
+// Generator calculates the codes for one key. The secret is decoded and the
+// HMAC set up once, so calculating a code allocates nothing. A Generator is
+// not safe for concurrent use; give each goroutine its own.
+//
+// As in RFC 4226, the truncated HMAC is reduced modulo 10^digits and zero
+// padded, so 8-digit codes match those of hardware tokens. A key with no
+// digits set gets the six digits GetCode has always given it.
+type Generator struct {
+	mac    hash.Hash
+	digits int
+	mod    uint64
+	msg    [8]byte
+	sum    []byte
+}
+
+// NewGenerator returns a generator of codes of the number of digits for the
+// Base32-encoded secret.
+func NewGenerator(secret32 string, h Hash, digits int) (*Generator, error) {
+	// an empty secret is an empty HMAC key, as it always was for GetCode
+	if secret32 == "" {
+		return Secret(nil).Generator(h, digits)
+	}
+	s, err := secretFromSecret32(secret32)
+	if err != nil {
+		return nil, err
+	}
+	return s.Generator(h, digits)
+}
+
+// Generator returns a generator of codes of the number of digits for the
+// secret. Keys are checked by Validate, not here.
+func (s Secret) Generator(h Hash, digits int) (*Generator, error) {
+	g := &Generator{mac: hmac.New(h, s), digits: digits, mod: 1000000}
+	if digits > 0 {
+		// the truncated HMAC has at most 10 digits, so more can't reduce it
+		g.mod = 1
+		for i := 0; i < digits && i < 10; i++ {
+			g.mod *= 10
+		}
+	}
+	g.sum = make([]byte, 0, g.mac.Size())
+	return g, nil
+}
+
+// Generator returns a generator of the key's codes.
+func (k Key) Generator() (*Generator, error) {
+	return NewGenerator(k.Secret32, k.Algo, k.Digits)
+}
+
+// AppendCode appends the code for the initial value to dst and returns the
+// extended buffer. Reusing a buffer with room for the code, as in
+//
+//	buf = g.AppendCode(buf[:0], iv)
+//
+// makes no allocations.
+func (g *Generator) AppendCode(dst []byte, iv int64) []byte {
+	binary.BigEndian.PutUint64(g.msg[:], uint64(iv))
+	g.mac.Reset()
+	g.mac.Write(g.msg[:])
+	g.sum = g.mac.Sum(g.sum[:0])
+
+	// dynamic truncation, RFC 4226 section 5.3
+	offset := g.sum[len(g.sum)-1] & 0xf
+	code := uint64(binary.BigEndian.Uint32(g.sum[offset:offset+4])&0x7fffffff) % g.mod
+
+	n := 1
+	for c := code; c >= 10; c /= 10 {
+		n++
+	}
+	if n < g.digits {
+		n = g.digits
+	}
+
+	// fill the digits in from the right, leaving zero padding
+	start := len(dst)
+	for i := 0; i < n; i++ {
+		dst = append(dst, '0')
+	}
+	for i := len(dst) - 1; i >= start && code > 0; i-- {
+		dst[i] = byte('0' + code%10)
+		code /= 10
+	}
+	return dst
+}
+
+// Code returns the code for the initial value.
+func (g *Generator) Code(iv int64) string {
+	var buf [10]byte
+	return string(g.AppendCode(buf[:0], iv))
+}
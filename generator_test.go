@@ -0,0 +1,178 @@
+package otp
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base32"
+	"encoding/binary"
+	"strconv"
+	"testing"
+)
+
+// rfcSecret is the secret from the test vectors of RFC 4226 and RFC 6238,
+// "12345678901234567890", in Base32.
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestGeneratorRFC4226(t *testing.T) {
+	want := []string{"755224", "287082", "359152", "969429", "338314", "254676", "287922", "162583", "399871", "520489"}
+	g, err := NewGenerator(rfcSecret, sha1.New, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for iv, code := range want {
+		if got := g.Code(int64(iv)); got != code {
+			t.Errorf("Expected %v for counter %v, got %v", code, iv, got)
+		}
+	}
+}
+
+func TestGeneratorRFC6238(t *testing.T) {
+	tests := []struct {
+		secret string
+		h      Hash
+		time   int64
+		code   string
+	}{
+		{rfcSecret, sha1.New, 59, "94287082"},
+		{rfcSecret, sha1.New, 1111111109, "07081804"},
+		{rfcSecret, sha1.New, 20000000000, "65353130"},
+		{"GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZA====", sha256.New, 59, "46119246"},
+		{"GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNA=", sha512.New, 59, "90693936"},
+	}
+
+	for _, test := range tests {
+		g, err := NewGenerator(test.secret, test.h, 8)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := g.Code(test.time / 30); got != test.code {
+			t.Errorf("Expected %v at %v, got %v", test.code, test.time, got)
+		}
+	}
+}
+
+func TestGeneratorMatchesGetCode(t *testing.T) {
+	k := Key{Secret32: "MFRGGZDFMZTWQ2LK", Algo: sha1.New, Digits: 6}
+	g, err := k.Generator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := []byte("code: ")
+	for iv := int64(0); iv < 100; iv++ {
+		want, _ := GetCode(k.Secret32, iv, k.Algo, k.Digits)
+		if got := g.AppendCode(buf[:6], iv); string(got) != "code: "+want {
+			t.Errorf("Expected %q for %v, got %q", want, iv, got)
+		}
+	}
+}
+
+// baselineGetCode is GetCode as it was before Generator, kept to check the
+// 6-digit codes haven't changed.
+func baselineGetCode(secret32 string, iv int64, h Hash, digits int) (string, error) {
+	key, err := base32.StdEncoding.DecodeString(secret32)
+	if err != nil {
+		return "", err
+	}
+
+	msg := bytes.Buffer{}
+	binary.Write(&msg, binary.BigEndian, iv)
+
+	mac := hmac.New(h, key)
+	mac.Write(msg.Bytes())
+	digest := mac.Sum(nil)
+
+	offset := digest[len(digest)-1] & 0xF
+	trunc := digest[offset : offset+4]
+
+	var code int32
+	truncBytes := bytes.NewBuffer(trunc)
+	_ = binary.Read(truncBytes, binary.BigEndian, &code)
+
+	code = (code & 0x7FFFFFFF) % 1000000
+
+	stringCode := strconv.Itoa(int(code))
+	for len(stringCode) < digits {
+		stringCode = "0" + stringCode
+	}
+	return stringCode, nil
+}
+
+func TestGeneratorMatchesBaseline(t *testing.T) {
+	for _, secret := range []string{rfcSecret, "MFRGGZDFMZTWQ2LK", ""} {
+		for _, h := range []Hash{sha1.New, sha256.New, sha512.New} {
+			for _, digits := range []int{0, 6} {
+				g, err := NewGenerator(secret, h, digits)
+				if err != nil {
+					t.Fatalf("%q, %v digits: %v", secret, digits, err)
+				}
+				for iv := int64(0); iv < 200; iv++ {
+					want, _ := baselineGetCode(secret, iv, h, digits)
+					if got := g.Code(iv); got != want {
+						t.Fatalf("%q, %v digits, counter %v: expected %q, got %q", secret, digits, iv, want, got)
+					}
+					if got, err := GetCode(secret, iv, h, digits); got != want || err != nil {
+						t.Fatalf("GetCode %q, %v digits, counter %v: expected %q, got %q %v", secret, digits, iv, want, got, err)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestGeneratorErrors(t *testing.T) {
+	if _, err := NewGenerator("abc123", sha1.New, 6); err == nil {
+		t.Error("Expected an invalid secret to be refused")
+	}
+}
+
+func TestGeneratorAllocs(t *testing.T) {
+	for _, h := range []Hash{sha1.New, sha256.New, sha512.New} {
+		g, err := NewGenerator(rfcSecret, h, 6)
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf := make([]byte, 0, 6)
+		iv := int64(0)
+		allocs := testing.AllocsPerRun(1000, func() {
+			buf = g.AppendCode(buf[:0], iv)
+			iv++
+		})
+		if allocs != 0 {
+			t.Errorf("Expected no allocations per code, got %v", allocs)
+		}
+	}
+}
+
+func BenchmarkGetCode(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		GetCode(rfcSecret, int64(i), sha1.New, 6)
+	}
+}
+
+func BenchmarkGenerator(b *testing.B) {
+	for _, bench := range []struct {
+		name string
+		h    Hash
+	}{
+		{"SHA1", sha1.New},
+		{"SHA256", sha256.New},
+		{"SHA512", sha512.New},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			g, err := NewGenerator(rfcSecret, bench.h, 6)
+			if err != nil {
+				b.Fatal(err)
+			}
+			buf := make([]byte, 0, 6)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				buf = g.AppendCode(buf[:0], int64(i))
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+/*
+Package otpauth reads and writes otpauth URIs in the form password managers
+and authenticator apps use: secrets without padding, spaced or in lowercase,
+and the hash named by the algorithm parameter of Google's Key Uri Format
+rather than otp's algo.
+*/
+package otpauth
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"github.com/tristanwietsma/otp"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// ErrSteam is returned for Steam Guard keys, whose codes are letters
+// rather than digits.
+var ErrSteam = errors.New("Steam Guard codes are not supported")
+
+// Parse returns the key in the otpauth URI.
+func Parse(uri string) (otp.Key, error) {
+	u, err := url.Parse(strings.TrimSpace(uri))
+	if err != nil || u.Scheme != "otpauth" {
+		return otp.Key{}, otp.ErrInvalidURI
+	}
+
+	params := u.Query()
+	if params.Get("encoder") == "steam" {
+		return otp.Key{}, ErrSteam
+	}
+	// a bad secret is left for NewKey to report with the other fields
+	if s, err := otp.SecretFromBase32(params.Get("secret")); err == nil {
+		params.Set("secret", s.Base32())
+	}
+	if algo := params.Get("algorithm"); algo != "" && params.Get("algo") == "" {
+		params.Set("algo", algo)
+	}
+	u.RawQuery = params.Encode()
+
+	k, err := otp.NewKey(u.String())
+	return *k, err
+}
+
+// URI returns the key's otpauth URI in Google's Key Uri Format, with the
+// issuer before the label and the secret unpadded.
+func URI(k otp.Key) string {
+	label := k.Label
+	if k.Issuer != "" && !strings.HasPrefix(label, k.Issuer+":") {
+		label = k.Issuer + ":" + label
+	}
+	u := url.URL{Scheme: "otpauth", Host: k.Method, Path: "/" + label}
+
+	params := url.Values{}
+	params.Set("secret", strings.TrimRight(k.Secret32, "="))
+	if k.Issuer != "" {
+		params.Set("issuer", k.Issuer)
+	}
+	params.Set("algorithm", Algorithm(k.Algo))
+	params.Set("digits", strconv.Itoa(k.Digits))
+	if k.Method == "hotp" {
+		params.Set("counter", strconv.Itoa(k.Counter))
+	} else {
+		params.Set("period", strconv.Itoa(k.Period))
+	}
+	u.RawQuery = params.Encode()
+	return u.String()
+}
+
+// Algorithm returns the name of the hash in otpauth URIs, such as SHA1.
+func Algorithm(h otp.Hash) string {
+	// each supported hash has its own digest size
+	switch h().Size() {
+	case sha256.Size:
+		return "SHA256"
+	case sha512.Size:
+		return "SHA512"
+	case md5.Size:
+		return "MD5"
+	}
+	return "SHA1"
+}
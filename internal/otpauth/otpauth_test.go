@@ -0,0 +1,58 @@
+package otpauth
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"errors"
+	"github.com/tristanwietsma/otp"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	k, err := Parse("otpauth://totp/GitHub:alice?secret=mfrg+gzdf+mztw+q2lk&issuer=GitHub&algorithm=SHA256&digits=8&period=60")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k.Method != "totp" || k.Label != "GitHub:alice" || k.Secret32 != "MFRGGZDFMZTWQ2LK" || k.Issuer != "GitHub" || k.Digits != 8 || k.Period != 60 {
+		t.Errorf("Unexpected key %+v", k)
+	}
+	if Algorithm(k.Algo) != "SHA256" {
+		t.Errorf("Expected SHA256, got %v", Algorithm(k.Algo))
+	}
+
+	k, err = Parse("otpauth://totp/alice?secret=NAR5XTDD3EQU22YUGE")
+	if err != nil || k.Secret32 != "NAR5XTDD3EQU22YUGE======" || Algorithm(k.Algo) != "SHA1" {
+		t.Errorf("Expected an unpadded secret to be padded, got %+v %v", k, err)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		uri  string
+		want error
+	}{
+		{"https://example.com", otp.ErrInvalidURI},
+		{"otpauth://totp/Steam:alice?secret=MFRGGZDFMZTWQ2LK&encoder=steam", ErrSteam},
+		{"otpauth://totp/alice?secret=abc123", otp.ErrInvalidSecret},
+		{"otpauth://totp/alice", otp.ErrMissingSecret},
+	}
+	for _, tt := range tests {
+		if _, err := Parse(tt.uri); !errors.Is(err, tt.want) {
+			t.Errorf("%v: expected %v, got %v", tt.uri, tt.want, err)
+		}
+	}
+}
+
+func TestURI(t *testing.T) {
+	k := otp.Key{Method: "totp", Label: "alice", Issuer: "GitHub", Secret32: "NAR5XTDD3EQU22YUGE======", Algo: sha1.New, Digits: 6, Period: 30}
+	want := "otpauth://totp/GitHub:alice?algorithm=SHA1&digits=6&issuer=GitHub&period=30&secret=NAR5XTDD3EQU22YUGE"
+	if got := URI(k); got != want {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+
+	k = otp.Key{Method: "hotp", Label: "bob", Secret32: "MFRGGZDFMZTWQ2LK", Algo: sha256.New, Digits: 8, Counter: 3}
+	back, err := Parse(URI(k))
+	if err != nil || back.ToURI() != k.ToURI() {
+		t.Errorf("Expected %v to read back, got %+v %v", URI(k), back, err)
+	}
+}
@@ -0,0 +1,29 @@
+/*
+Package uuid generates the random version 4 UUIDs that password manager
+exports identify their entries and folders by.
+*/
+package uuid
+
+import (
+	"crypto/rand"
+	"fmt"
+)
+
+// UUID is a random version 4 UUID.
+type UUID [16]byte
+
+// New returns a random UUID.
+func New() (UUID, error) {
+	var u UUID
+	if _, err := rand.Read(u[:]); err != nil {
+		return UUID{}, fmt.Errorf("unable to generate UUID: %w", err)
+	}
+	u[6] = u[6]&0x0f | 0x40 // version 4
+	u[8] = u[8]&0x3f | 0x80 // RFC 4122 variant
+	return u, nil
+}
+
+// String returns the UUID in its usual hyphenated hex form.
+func (u UUID) String() string {
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[:4], u[4:6], u[6:8], u[8:10], u[10:])
+}
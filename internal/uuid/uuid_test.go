@@ -0,0 +1,22 @@
+package uuid
+
+import (
+	"regexp"
+	"testing"
+)
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+func TestNew(t *testing.T) {
+	a, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := New()
+	if a == b {
+		t.Errorf("UUIDs are not random: %v %v", a, b)
+	}
+	if !uuidPattern.MatchString(a.String()) {
+		t.Errorf("Not a version 4 UUID: %v", a)
+	}
+}
@@ -0,0 +1,97 @@
+package keepassxc
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// RecycleBin is the default name of the group deleted entries are moved to.
+const RecycleBin = "Recycle Bin"
+
+// csvHeader is the header of KeePassXC's CSV export. Its Group column holds
+// the path from the root group, and its TOTP column an otpauth URI.
+var csvHeader = []string{"Group", "Title", "Username", "Password", "URL", "Notes", "TOTP", "Icon", "Last Modified", "Created"}
+
+// ReadCSV returns the entries with one-time passwords in a CSV export,
+// leaving out those in the recycle bin. Columns are found by name, so only
+// Title and TOTP are required. An entry whose key can't be read is returned
+// with Err set.
+//
+// The CSV export doesn't mark the recycle bin, so it is found by its default
+// name, RecycleBin; a renamed or translated one is read like any group.
+func ReadCSV(r io.Reader) ([]Entry, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, errors.New("empty CSV export")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	cols := map[string]int{}
+	for i, name := range header {
+		cols[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"title", "totp"} {
+		if _, ok := cols[name]; !ok {
+			return nil, fmt.Errorf("CSV export has no %v column", name)
+		}
+	}
+	field := func(row []string, name string) string {
+		if i, ok := cols[name]; ok && i < len(row) {
+			return row[i]
+		}
+		return ""
+	}
+
+	entries := []Entry{}
+	for {
+		row, err := cr.Read()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// the root group, usually named Root, isn't part of the path
+		group := field(row, "group")
+		if i := strings.Index(group, "/"); i >= 0 {
+			group = group[i+1:]
+		} else {
+			group = ""
+		}
+		if group == RecycleBin || strings.HasPrefix(group, RecycleBin+"/") {
+			continue
+		}
+		attrs := map[string]string{AttrOTP: field(row, "totp")}
+		if e, ok := newEntry(group, field(row, "title"), field(row, "username"), attrs); ok {
+			entries = append(entries, e)
+		}
+	}
+}
+
+// WriteCSV writes the entries in the layout of KeePassXC's CSV export, for
+// its CSV import.
+func WriteCSV(w io.Writer, entries []Entry) error {
+	cw := csv.NewWriter(w)
+	cw.Write(csvHeader)
+	for _, e := range entries {
+		attrs, err := Attributes(e.Key, false)
+		if err != nil {
+			return entryError(e, err)
+		}
+		group := "Root"
+		if g := strings.Trim(e.Group, "/"); g != "" {
+			group += "/" + g
+		}
+		cw.Write([]string{group, e.Title, e.Username, "", "", "", attrs[AttrOTP], "0", "", ""})
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,55 @@
+package keepassxc
+
+import (
+	"bytes"
+	"errors"
+	"github.com/tristanwietsma/otp"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestReadCSV(t *testing.T) {
+	f, err := os.Open("testdata/export.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	entries, err := ReadCSV(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 3 {
+		t.Fatalf("Expected 3 entries, got %+v", entries)
+	}
+	if e := entries[0]; e.Group != "" || e.Title != "GitHub" || e.Username != "alice" || e.Key.Secret32 != "MFRGGZDFMZTWQ2LK" || e.Err != nil {
+		t.Errorf("Unexpected entry %+v", e)
+	}
+	if e := entries[1]; e.Group != "Work/AWS" || e.Key.Digits != 8 || e.Key.Period != 60 || e.Err != nil {
+		t.Errorf("Unexpected entry %+v", e)
+	}
+	if e := entries[2]; e.Title != "Broken" || !errors.Is(e.Err, otp.ErrInvalidSecret) {
+		t.Errorf("Expected the broken entry to fail, got %+v", e)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, entries[:2]); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(buf.String(), "Group,Title,Username,Password,URL,Notes,TOTP,") || !strings.Contains(buf.String(), "\nRoot/Work/AWS,Amazon,root,") {
+		t.Errorf("Unexpected CSV\n%v", buf.String())
+	}
+	back, err := ReadCSV(&buf)
+	if err != nil || len(back) != 2 || back[1].Key.ToURI() != entries[1].Key.ToURI() {
+		t.Errorf("Expected the entries back, got %+v %v", back, err)
+	}
+}
+
+func TestReadCSVErrors(t *testing.T) {
+	for _, in := range []string{"", "Group,Title\nRoot,x\n", "Title,TOTP\n\"x\n"} {
+		if _, err := ReadCSV(strings.NewReader(in)); err == nil {
+			t.Errorf("%q: expected an error", in)
+		}
+	}
+}
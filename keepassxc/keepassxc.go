@@ -0,0 +1,162 @@
+/*
+Package keepassxc reads and writes the one-time password fields of KeePassXC
+entries, in its XML and CSV exports.
+
+KeePassXC keeps an entry's key in its otp attribute, usually as an otpauth
+URI, or as the key=...&step=...&size=... form of the KeeOtp plugin. Older
+versions used a pair of attributes instead: TOTP Seed, holding the Base32
+secret, and TOTP Settings, holding the period and digits as "30;6".
+
+Entries in the recycle bin are deleted, so the readers leave them out.
+*/
+package keepassxc
+
+import (
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"github.com/tristanwietsma/otp"
+	"github.com/tristanwietsma/otp/internal/otpauth"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Names of the attributes holding an entry's key.
+const (
+	AttrOTP      = "otp"
+	AttrSeed     = "TOTP Seed"
+	AttrSettings = "TOTP Settings"
+)
+
+// ErrNoOTP is returned by ParseAttributes for an entry without a key.
+var ErrNoOTP = errors.New("entry has no one-time password")
+
+// Entry is a KeePassXC entry with a one-time password.
+type Entry struct {
+	Group    string  // Slash-separated path of the entry's group below the root.
+	Title    string  // Title of the entry.
+	Username string  // Username of the entry.
+	Key      otp.Key // The entry's key, labeled with its title.
+	Err      error   // Why the key couldn't be read, if it couldn't.
+}
+
+// ParseAttributes returns the key held in an entry's attributes, preferring
+// otp to the legacy pair.
+func ParseAttributes(attrs map[string]string) (otp.Key, error) {
+	if v := strings.TrimSpace(attrs[AttrOTP]); v != "" {
+		if strings.HasPrefix(v, "otpauth://") {
+			return otpauth.Parse(v)
+		}
+		return parseKeeOtp(v)
+	}
+	if seed := strings.TrimSpace(attrs[AttrSeed]); seed != "" {
+		return parseLegacy(seed, attrs[AttrSettings])
+	}
+	return otp.Key{}, ErrNoOTP
+}
+
+// parseKeeOtp parses a key in the KeeOtp plugin's form.
+func parseKeeOtp(v string) (otp.Key, error) {
+	params, err := url.ParseQuery(v)
+	if err != nil || params.Get("key") == "" {
+		return otp.Key{}, fmt.Errorf("otp attribute %q is neither an otpauth URI nor KeeOtp settings", v)
+	}
+	if t := params.Get("type"); t != "" && !strings.EqualFold(t, "totp") {
+		return otp.Key{}, fmt.Errorf("unsupported KeeOtp type %q", t)
+	}
+
+	k := otp.Key{Method: "totp", Algo: sha1.New, Digits: 6, Period: 30}
+	secret, err := otp.SecretFromBase32(params.Get("key"))
+	if err != nil {
+		return otp.Key{}, err
+	}
+	k.SetSecret(secret)
+	if step := params.Get("step"); step != "" {
+		if k.Period, err = strconv.Atoi(step); err != nil {
+			return otp.Key{}, otp.ErrInvalidPeriod
+		}
+	}
+	if size := params.Get("size"); size != "" {
+		if k.Digits, err = strconv.Atoi(size); err != nil {
+			return otp.Key{}, otp.ErrInvalidDigits
+		}
+	}
+	return k, nil
+}
+
+// parseLegacy parses a key from the TOTP Seed and TOTP Settings pair.
+func parseLegacy(seed, settings string) (otp.Key, error) {
+	k := otp.Key{Method: "totp", Algo: sha1.New, Digits: 6, Period: 30}
+	secret, err := otp.SecretFromBase32(seed)
+	if err != nil {
+		return otp.Key{}, err
+	}
+	k.SetSecret(secret)
+
+	settings = strings.TrimSpace(settings)
+	if settings == "" {
+		return k, nil
+	}
+	parts := strings.Split(settings, ";")
+	if len(parts) != 2 {
+		return otp.Key{}, fmt.Errorf("TOTP Settings %q are not period;digits", settings)
+	}
+	period, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return otp.Key{}, otp.ErrInvalidPeriod
+	}
+	if parts[1] == "S" {
+		return otp.Key{}, otpauth.ErrSteam
+	}
+	digits, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return otp.Key{}, otp.ErrInvalidDigits
+	}
+	k.Period, k.Digits = period, digits
+	return k, nil
+}
+
+// Attributes returns the attributes holding the key: otp, with an otpauth
+// URI, or with legacy set, the TOTP Seed and TOTP Settings pair older
+// versions read. The pair can only describe SHA-1 totp keys.
+func Attributes(k otp.Key, legacy bool) (map[string]string, error) {
+	if err := k.Validate(); err != nil {
+		return nil, err
+	}
+	if !legacy {
+		return map[string]string{AttrOTP: otpauth.URI(k)}, nil
+	}
+
+	if k.Method != "totp" || otpauth.Algorithm(k.Algo) != "SHA1" {
+		return nil, errors.New("legacy attributes only hold SHA1 totp keys")
+	}
+	return map[string]string{
+		AttrSeed:     k.Secret32,
+		AttrSettings: fmt.Sprintf("%d;%d", k.Period, k.Digits),
+	}, nil
+}
+
+// newEntry returns the entry with the key read from its attributes, and
+// reports false if it has none.
+func newEntry(group, title, username string, attrs map[string]string) (Entry, bool) {
+	e := Entry{Group: group, Title: title, Username: username}
+	k, err := ParseAttributes(attrs)
+	if err == ErrNoOTP {
+		return e, false
+	}
+	if err == nil {
+		k.Label = title
+		if k.Label == "" {
+			k.Label = username
+		}
+		err = k.Validate()
+	}
+	e.Key, e.Err = k, err
+	return e, true
+}
+
+// entryError names the entry an error is about.
+func entryError(e Entry, err error) error {
+	return fmt.Errorf("%v: %w", e.Title, err)
+}
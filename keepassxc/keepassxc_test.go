@@ -0,0 +1,88 @@
+package keepassxc
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"errors"
+	"github.com/tristanwietsma/otp"
+	"github.com/tristanwietsma/otp/internal/otpauth"
+	"testing"
+)
+
+func TestParseAttributes(t *testing.T) {
+	tests := []struct {
+		attrs  map[string]string
+		secret string
+		digits int
+		period int
+	}{
+		{map[string]string{AttrOTP: "otpauth://totp/x?secret=MFRGGZDFMZTWQ2LK&digits=8&period=60"}, "MFRGGZDFMZTWQ2LK", 8, 60},
+		{map[string]string{AttrOTP: "key=mfrggzdfmztwq2lk&step=45&size=8"}, "MFRGGZDFMZTWQ2LK", 8, 45},
+		{map[string]string{AttrOTP: "key=MFRGGZDFMZTWQ2LK"}, "MFRGGZDFMZTWQ2LK", 6, 30},
+		{map[string]string{AttrSeed: "NAR5 XTDD 3EQU 22YU", AttrSettings: "60;8"}, "NAR5XTDD3EQU22YU", 8, 60},
+		{map[string]string{AttrSeed: "NAR5XTDD3EQU22YUGE"}, "NAR5XTDD3EQU22YUGE======", 6, 30},
+		// otp wins over the legacy pair
+		{map[string]string{AttrOTP: "key=MFRGGZDFMZTWQ2LK", AttrSeed: "NAR5XTDD3EQU22YU"}, "MFRGGZDFMZTWQ2LK", 6, 30},
+	}
+	for _, tt := range tests {
+		k, err := ParseAttributes(tt.attrs)
+		if err != nil {
+			t.Errorf("%v: %v", tt.attrs, err)
+			continue
+		}
+		if k.Method != "totp" || k.Secret32 != tt.secret || k.Digits != tt.digits || k.Period != tt.period {
+			t.Errorf("%v: unexpected key %+v", tt.attrs, k)
+		}
+	}
+}
+
+func TestParseAttributesErrors(t *testing.T) {
+	tests := []struct {
+		attrs map[string]string
+		want  error
+	}{
+		{map[string]string{"Title": "x"}, ErrNoOTP},
+		{map[string]string{AttrOTP: "  "}, ErrNoOTP},
+		{map[string]string{AttrSeed: "MFRGGZDFMZTWQ2LK", AttrSettings: "30;S"}, otpauth.ErrSteam},
+		{map[string]string{AttrSeed: "MFRGGZDFMZTWQ2LK", AttrSettings: "soon;6"}, otp.ErrInvalidPeriod},
+		{map[string]string{AttrOTP: "key=MFRGGZDFMZTWQ2LK&size=six"}, otp.ErrInvalidDigits},
+	}
+	for _, tt := range tests {
+		if _, err := ParseAttributes(tt.attrs); !errors.Is(err, tt.want) {
+			t.Errorf("%v: expected %v, got %v", tt.attrs, tt.want, err)
+		}
+	}
+
+	for _, attrs := range []map[string]string{
+		{AttrOTP: "hunter2"},
+		{AttrOTP: "key=MFRGGZDFMZTWQ2LK&type=hotp"},
+		{AttrSeed: "MFRGGZDFMZTWQ2LK", AttrSettings: "30;6;SHA1"},
+	} {
+		if _, err := ParseAttributes(attrs); err == nil {
+			t.Errorf("%v: expected an error", attrs)
+		}
+	}
+}
+
+func TestAttributes(t *testing.T) {
+	k := otp.Key{Method: "totp", Label: "GitHub", Secret32: "MFRGGZDFMZTWQ2LK", Algo: sha1.New, Digits: 6, Period: 30}
+
+	attrs, err := Attributes(k, false)
+	if err != nil || attrs[AttrOTP] != "otpauth://totp/GitHub?algorithm=SHA1&digits=6&period=30&secret=MFRGGZDFMZTWQ2LK" {
+		t.Errorf("Unexpected attributes %v %v", attrs, err)
+	}
+
+	attrs, err = Attributes(k, true)
+	if err != nil || attrs[AttrSeed] != "MFRGGZDFMZTWQ2LK" || attrs[AttrSettings] != "30;6" || len(attrs) != 2 {
+		t.Errorf("Unexpected legacy attributes %v %v", attrs, err)
+	}
+
+	k.Algo = sha256.New
+	if _, err := Attributes(k, true); err == nil {
+		t.Error("Expected legacy attributes to refuse SHA256")
+	}
+	k.Secret32 = "abc123"
+	if _, err := Attributes(k, false); !errors.Is(err, otp.ErrInvalidSecret) {
+		t.Errorf("Expected an invalid key to be refused, got %v", err)
+	}
+}
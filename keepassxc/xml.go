@@ -0,0 +1,175 @@
+package keepassxc
+
+import (
+	"encoding/base64"
+	"encoding/xml"
+	"github.com/tristanwietsma/otp/internal/uuid"
+	"io"
+	"strings"
+)
+
+// xmlFile is an unencrypted KeePass XML file, as written by
+// keepassxc-cli export --format xml.
+type xmlFile struct {
+	XMLName xml.Name `xml:"KeePassFile"`
+	Meta    xmlMeta  `xml:"Meta"`
+	Root    struct {
+		Groups []*xmlGroup `xml:"Group"`
+	} `xml:"Root"`
+}
+
+type xmlMeta struct {
+	Generator      string `xml:"Generator"`
+	RecycleBinUUID string `xml:"RecycleBinUUID,omitempty"`
+}
+
+type xmlGroup struct {
+	UUID    string      `xml:"UUID"`
+	Name    string      `xml:"Name"`
+	Entries []xmlEntry  `xml:"Entry"`
+	Groups  []*xmlGroup `xml:"Group"`
+}
+
+// xmlEntry is an entry; its History is left out, so old versions of entries
+// aren't read.
+type xmlEntry struct {
+	UUID    string      `xml:"UUID"`
+	Strings []xmlString `xml:"String"`
+}
+
+type xmlString struct {
+	Key   string   `xml:"Key"`
+	Value xmlValue `xml:"Value"`
+}
+
+type xmlValue struct {
+	ProtectInMemory string `xml:"ProtectInMemory,attr,omitempty"`
+	Text            string `xml:",chardata"`
+}
+
+// ReadXML returns the entries with one-time passwords in an unencrypted XML
+// export, leaving out those in the recycle bin. An entry whose key can't be
+// read is returned with Err set.
+func ReadXML(r io.Reader) ([]Entry, error) {
+	var f xmlFile
+	if err := xml.NewDecoder(r).Decode(&f); err != nil {
+		return nil, err
+	}
+
+	entries := []Entry{}
+	var walk func(g *xmlGroup, path string)
+	walk = func(g *xmlGroup, path string) {
+		for _, xe := range g.Entries {
+			attrs := map[string]string{}
+			for _, s := range xe.Strings {
+				attrs[s.Key] = s.Value.Text
+			}
+			if e, ok := newEntry(path, attrs["Title"], attrs["UserName"], attrs); ok {
+				entries = append(entries, e)
+			}
+		}
+		for _, sub := range g.Groups {
+			if f.Meta.RecycleBinUUID != "" && sub.UUID == f.Meta.RecycleBinUUID {
+				continue
+			}
+			walk(sub, strings.TrimPrefix(path+"/"+sub.Name, "/"))
+		}
+	}
+	// the root group, usually named Root, isn't part of the path
+	for _, g := range f.Root.Groups {
+		walk(g, "")
+	}
+	return entries, nil
+}
+
+// WriteXML writes the entries as an unencrypted XML file that
+// keepassxc-cli import turns into a database. With legacy set, keys are
+// written to the TOTP Seed and TOTP Settings attributes rather than otp.
+func WriteXML(w io.Writer, entries []Entry, legacy bool) error {
+	id, err := newUUID()
+	if err != nil {
+		return err
+	}
+	root := &xmlGroup{UUID: id, Name: "Root"}
+	groups := map[string]*xmlGroup{"": root}
+
+	for _, e := range entries {
+		attrs, err := Attributes(e.Key, legacy)
+		if err != nil {
+			return entryError(e, err)
+		}
+		id, err := newUUID()
+		if err != nil {
+			return err
+		}
+		xe := xmlEntry{UUID: id}
+		xe.add("Title", e.Title, false)
+		xe.add("UserName", e.Username, false)
+		for _, name := range []string{AttrOTP, AttrSeed, AttrSettings} {
+			if v, ok := attrs[name]; ok {
+				xe.add(name, v, name != AttrSettings)
+			}
+		}
+
+		g, err := groupAt(groups, strings.Trim(e.Group, "/"))
+		if err != nil {
+			return err
+		}
+		g.Entries = append(g.Entries, xe)
+	}
+
+	f := xmlFile{Meta: xmlMeta{Generator: "2fa"}}
+	f.Root.Groups = []*xmlGroup{root}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "\t")
+	if err := enc.Encode(f); err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "\n")
+	return err
+}
+
+// add appends an attribute to the entry.
+func (xe *xmlEntry) add(key, value string, protect bool) {
+	s := xmlString{Key: key, Value: xmlValue{Text: value}}
+	if protect {
+		s.Value.ProtectInMemory = "True"
+	}
+	xe.Strings = append(xe.Strings, s)
+}
+
+// groupAt returns the group at the slash-separated path, creating it and
+// its parents as needed.
+func groupAt(groups map[string]*xmlGroup, path string) (*xmlGroup, error) {
+	if g, ok := groups[path]; ok {
+		return g, nil
+	}
+	parent, name := "", path
+	if i := strings.LastIndex(path, "/"); i >= 0 {
+		parent, name = path[:i], path[i+1:]
+	}
+	p, err := groupAt(groups, parent)
+	if err != nil {
+		return nil, err
+	}
+	id, err := newUUID()
+	if err != nil {
+		return nil, err
+	}
+	g := &xmlGroup{UUID: id, Name: name}
+	p.Groups = append(p.Groups, g)
+	groups[path] = g
+	return g, nil
+}
+
+// newUUID returns a random UUID in the Base64 form of KeePass files.
+func newUUID() (string, error) {
+	u, err := uuid.New()
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(u[:]), nil
+}
@@ -0,0 +1,101 @@
+package keepassxc
+
+import (
+	"bytes"
+	"errors"
+	"github.com/tristanwietsma/otp/internal/otpauth"
+	"os"
+	"strings"
+	"testing"
+)
+
+func readXMLFixture(t *testing.T) []Entry {
+	f, err := os.Open("testdata/export.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	entries, err := ReadXML(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return entries
+}
+
+func TestReadXML(t *testing.T) {
+	entries := readXMLFixture(t)
+
+	want := []struct {
+		group, title, username, secret string
+		period, digits                 int
+	}{
+		{"", "GitHub", "alice", "MFRGGZDFMZTWQ2LK", 30, 6},
+		{"", "Steam", "", "", 0, 0},
+		{"Work", "VPN", "", "MFRGGZDFMZTWQ2LK", 30, 6},
+		{"Work/AWS", "Amazon", "root", "NAR5XTDD3EQU22YU", 60, 8},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("Expected %v entries, got %+v", len(want), entries)
+	}
+	for i, w := range want {
+		e := entries[i]
+		if e.Group != w.group || e.Title != w.title || e.Username != w.username {
+			t.Errorf("Expected %+v, got %+v", w, e)
+		}
+		if w.title == "Steam" {
+			if !errors.Is(e.Err, otpauth.ErrSteam) {
+				t.Errorf("Expected the Steam entry to fail, got %v", e.Err)
+			}
+			continue
+		}
+		if e.Err != nil || e.Key.Label != w.title || e.Key.Secret32 != w.secret || e.Key.Period != w.period || e.Key.Digits != w.digits {
+			t.Errorf("Expected %+v, got %+v (%v)", w, e.Key, e.Err)
+		}
+	}
+}
+
+func TestWriteXML(t *testing.T) {
+	var entries []Entry
+	for _, e := range readXMLFixture(t) {
+		if e.Err == nil {
+			entries = append(entries, e)
+		}
+	}
+
+	for _, legacy := range []bool{false, true} {
+		var buf bytes.Buffer
+		if err := WriteXML(&buf, entries, legacy); err != nil {
+			t.Fatal(err)
+		}
+		out := buf.String()
+		if strings.Contains(out, "<Key>otp</Key>") == legacy || strings.Contains(out, "<Key>TOTP Seed</Key>") != legacy {
+			t.Errorf("Expected legacy=%v attributes, got\n%v", legacy, out)
+		}
+
+		back, err := ReadXML(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(back) != len(entries) {
+			t.Fatalf("Expected %v entries back, got %v", len(entries), len(back))
+		}
+		for i := range back {
+			want := entries[i].Key
+			if legacy {
+				// the legacy pair has no issuer
+				want.Issuer = ""
+			}
+			if back[i].Group != entries[i].Group || back[i].Key.ToURI() != want.ToURI() {
+				t.Errorf("Expected %+v back, got %+v", entries[i], back[i])
+			}
+		}
+	}
+}
+
+func TestWriteXMLErrors(t *testing.T) {
+	e := readXMLFixture(t)[0]
+	e.Key.Secret32 = "abc123"
+	if err := WriteXML(&bytes.Buffer{}, []Entry{e}, false); err == nil || !strings.HasPrefix(err.Error(), "GitHub: ") {
+		t.Errorf("Expected an error naming the entry, got %v", err)
+	}
+}
@@ -1,6 +1,9 @@
 package otp
 
-import "strings"
+import (
+	"crypto/rand"
+	"strings"
+)
 
 func newKey(method, label, secret, issuer string, algo Hash, digits, period, counter int) (*Key, error) {
 
@@ -46,16 +49,28 @@ func NewHOTPKey(
 	return k, err
 }
 
-// NewKey returns a key from an otpauth URI.
+// NewKey returns a key from an otpauth URI. Errors in the URI and in the
+// key it describes are reported together as a *ValidationError.
 func NewKey(uri string) (*Key, error) {
 	k := Key{}
-	if err := k.FromURI(uri); err != nil {
-		return &k, err
+	verr := k.fromURI(uri)
+	if !verr.has("URI") {
+		// report each field once, preferring the parse error
+		for _, f := range k.validate().Fields {
+			if !verr.has(f.Field) {
+				verr.add(f.Field, f.Err)
+			}
+		}
 	}
+	return &k, verr.err()
+}
 
-	if err := k.Validate(); err != nil {
-		return &k, err
+// NewSecret32 returns a random 160-bit secret, Base32-encoded, the length
+// RFC 4226 recommends.
+func NewSecret32() (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
 	}
-
-	return &k, nil
+	return Secret(b).Base32(), nil
 }
@@ -2,6 +2,7 @@ package otp
 
 import (
 	"crypto/sha1"
+	"errors"
 	"testing"
 )
 
@@ -74,3 +75,43 @@ func TestNewKeyFromURI(t *testing.T) {
 	}
 
 }
+
+func TestNewKeyErrors(t *testing.T) {
+	uri := "otpauth://totp/label?secret=abc123&digits=seven&period=0"
+	_, err := NewKey(uri)
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("Expected a *ValidationError, got %v", err)
+	}
+	if len(verr.Fields) != 3 {
+		t.Errorf("Expected each failing field once, got %v", verr)
+	}
+	for _, want := range []error{ErrInvalidDigits, ErrInvalidSecret, ErrInvalidPeriod} {
+		if !errors.Is(err, want) {
+			t.Errorf("Expected %v to include %q", err, want)
+		}
+	}
+
+	if _, err := NewKey("blahblah"); !errors.Is(err, ErrInvalidURI) {
+		t.Errorf("Expected %q, got %v", ErrInvalidURI, err)
+	}
+
+	_, err = NewTOTPKey("", "MFRGGZDFMZTWQ2LK", "issuer", sha1.New, 6, 30)
+	if !errors.As(err, &verr) || !errors.Is(err, ErrMissingLabel) {
+		t.Errorf("Expected %q from NewTOTPKey, got %v", ErrMissingLabel, err)
+	}
+}
+
+func TestNewSecret32(t *testing.T) {
+	a, err := NewSecret32()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := NewSecret32()
+	if len(a) != 32 || a == b {
+		t.Errorf("Expected distinct 32-character secrets, got %q %q", a, b)
+	}
+	if _, err := NewTOTPKey("label", a, "", sha1.New, 6, 30); err != nil {
+		t.Errorf("Expected a valid secret, got %v", err)
+	}
+}
@@ -0,0 +1,42 @@
+package otp
+
+import "sync"
+
+// keyLocks serializes work on each of a set of names, such as users.
+type keyLocks struct {
+	mu    sync.Mutex
+	locks map[string]*keyLock
+}
+
+// keyLock is the lock for one name. It is dropped once nothing holds or
+// waits for it.
+type keyLock struct {
+	sync.Mutex
+	refs int
+}
+
+// lock acquires the name's lock and returns the function to release it.
+func (l *keyLocks) lock(name string) func() {
+	l.mu.Lock()
+	if l.locks == nil {
+		l.locks = map[string]*keyLock{}
+	}
+	k, ok := l.locks[name]
+	if !ok {
+		k = &keyLock{}
+		l.locks[name] = k
+	}
+	k.refs++
+	l.mu.Unlock()
+
+	k.Lock()
+	return func() {
+		k.Unlock()
+		l.mu.Lock()
+		k.refs--
+		if k.refs == 0 {
+			delete(l.locks, name)
+		}
+		l.mu.Unlock()
+	}
+}
@@ -0,0 +1,73 @@
+// Command example serves a page that requires a one-time password.
+//
+// Run it, add the printed URI to an authenticator app, then visit
+// http://127.0.0.1:8080/admin and submit a code.
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"flag"
+	"fmt"
+	"github.com/tristanwietsma/otp"
+	"github.com/tristanwietsma/otp/otphttp"
+	"log"
+	"net/http"
+)
+
+const form = `<!DOCTYPE html>
+<title>Step up</title>
+<form method="post">
+<label>One-time password <input name="otp" autocomplete="one-time-code" autofocus></label>
+<button>Continue</button>
+</form>
+`
+
+func main() {
+	addr := flag.String("addr", "127.0.0.1:8080", "listen address")
+	secret := flag.String("secret", "MFRGGZDFMZTWQ2LK", "Base32 secret of the admin user's key")
+	flag.Parse()
+
+	key, err := otp.NewTOTPKey("admin", *secret, "otphttp example", sha1.New, 6, 30)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("add this key to your authenticator:", key.ToURI())
+
+	cookieKey := make([]byte, 32)
+	if _, err := rand.Read(cookieKey); err != nil {
+		log.Fatal(err)
+	}
+
+	// a real server would identify users from its session and load their
+	// keys from a database
+	user := func(r *http.Request) (string, error) {
+		return "admin", nil
+	}
+	lookup := func(name string) (otp.Key, error) {
+		if name != "admin" {
+			return otp.Key{}, otp.ErrUnknownUser
+		}
+		return *key, nil
+	}
+
+	stepUp := otphttp.NewStepUp(cookieKey, user, lookup)
+	stepUp.Auth.Throttle = otp.NewThrottle(otp.NewMemoryAttemptStore())
+	// the example serves plain http; drop this behind https
+	stepUp.Secure = false
+	stepUp.Challenge = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, form)
+	})
+
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "public page; the admin page is at /admin")
+	})
+	http.Handle("/admin", stepUp.Require(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "welcome to the admin page")
+	})))
+
+	log.Printf("listening on http://%v", *addr)
+	log.Fatal(http.ListenAndServe(*addr, nil))
+}
@@ -0,0 +1,243 @@
+/*
+Package otphttp provides net/http middleware requiring a one-time password
+before serving sensitive routes.
+*/
+package otphttp
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"github.com/tristanwietsma/otp"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// StepUp is middleware that asks the signed-in user for a code before
+// serving a route. The code is read from a header or form field and checked
+// by an otp.Authenticator, so codes can't be replayed. Success is remembered
+// in a signed cookie until it expires.
+type StepUp struct {
+	User   func(r *http.Request) (string, error) // Identifies the signed-in user; "" is refused.
+	Auth   *otp.Authenticator                    // Checks codes. Its Window and Throttle may be changed before serving.
+	Secret []byte                                // Key signing the cookie; keep it secret and at least 32 bytes.
+	MaxAge time.Duration                         // How long a step-up lasts.
+	Header string                                // Request header carrying the code.
+	Field  string                                // Form field carrying the code.
+	Cookie string                                // Name of the step-up cookie.
+	Secure bool                                  // Whether the cookie is only sent over https; clear it only to serve plain http.
+	Now    func() time.Time                      // Clock. Defaults to time.Now.
+
+	// Challenge writes the response asking for a code. By default it is a
+	// plain 401 Unauthorized.
+	Challenge http.Handler
+}
+
+// NewStepUp returns middleware that identifies users with the user callback
+// and looks up their keys with the key callback, which should return
+// otp.ErrUnknownUser for a user without one. Step-ups last 15 minutes, the
+// cookie is marked Secure, and codes are read from the X-OTP-Code header or
+// the otp form field.
+func NewStepUp(secret []byte, user func(r *http.Request) (string, error), key func(user string) (otp.Key, error)) *StepUp {
+	s := &StepUp{
+		User:   user,
+		Secret: secret,
+		MaxAge: 15 * time.Minute,
+		Header: "X-OTP-Code",
+		Field:  "otp",
+		Cookie: "otp_stepup",
+		Secure: true,
+		Now:    time.Now,
+	}
+	s.Auth = otp.NewAuthenticator(&lookupStore{key: key, state: map[string]otp.Record{}, stale: s.stale, now: s.now})
+	s.Auth.Now = s.now
+	return s
+}
+
+// now returns the current time from the middleware's clock.
+func (s *StepUp) now() time.Time {
+	if s.Now == nil {
+		return time.Now()
+	}
+	return s.Now()
+}
+
+// Require wraps next so it is only served to users who have stepped up.
+func (s *StepUp) Require(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, err := s.User(r)
+		if err != nil || user == "" {
+			http.Error(w, "sign in required", http.StatusUnauthorized)
+			return
+		}
+
+		if c, err := r.Cookie(s.Cookie); err == nil && s.valid(c.Value, user) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		code := r.Header.Get(s.Header)
+		if code == "" && r.Method == "POST" {
+			code = r.PostFormValue(s.Field)
+		}
+		if code == "" {
+			s.challenge(w, r)
+			return
+		}
+
+		ok, err := s.Auth.Verify(user, code)
+		var throttled *otp.ThrottledError
+		switch {
+		case errors.As(err, &throttled):
+			retry := int(math.Ceil(throttled.Until.Sub(s.now()).Seconds()))
+			if retry < 1 {
+				retry = 1
+			}
+			w.Header().Set("Retry-After", strconv.Itoa(retry))
+			http.Error(w, "too many failed attempts", http.StatusTooManyRequests)
+			return
+		case errors.Is(err, otp.ErrUnknownUser):
+			http.Error(w, "no key enrolled", http.StatusForbidden)
+			return
+		case err != nil && !errors.Is(err, otp.ErrReplayedCode):
+			http.Error(w, "unable to verify code", http.StatusInternalServerError)
+			return
+		case !ok:
+			s.challenge(w, r)
+			return
+		}
+
+		http.SetCookie(w, &http.Cookie{
+			Name:     s.Cookie,
+			Value:    s.sign(user, s.now().Add(s.MaxAge)),
+			Path:     "/",
+			MaxAge:   int(s.MaxAge.Seconds()),
+			Secure:   s.Secure,
+			HttpOnly: true,
+			SameSite: http.SameSiteStrictMode,
+		})
+		next.ServeHTTP(w, r)
+	})
+}
+
+// challenge asks for a code.
+func (s *StepUp) challenge(w http.ResponseWriter, r *http.Request) {
+	if s.Challenge != nil {
+		s.Challenge.ServeHTTP(w, r)
+		return
+	}
+	w.Header().Set("WWW-Authenticate", `OTP header="`+s.Header+`"`)
+	http.Error(w, "one-time password required", http.StatusUnauthorized)
+}
+
+// sign returns a cookie value binding the user to the expiry.
+func (s *StepUp) sign(user string, expires time.Time) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(user)) + "." + strconv.FormatInt(expires.Unix(), 10)
+	return payload + "." + s.mac(payload)
+}
+
+// valid reports whether the cookie value was signed for the user and has
+// not expired.
+func (s *StepUp) valid(value, user string) bool {
+	i := strings.LastIndex(value, ".")
+	if i < 0 {
+		return false
+	}
+	payload, sig := value[:i], value[i+1:]
+	if !hmac.Equal([]byte(sig), []byte(s.mac(payload))) {
+		return false
+	}
+
+	parts := strings.Split(payload, ".")
+	if len(parts) != 2 {
+		return false
+	}
+	name, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil || string(name) != user {
+		return false
+	}
+	expires, err := strconv.ParseInt(parts[1], 10, 64)
+	return err == nil && s.now().Unix() < expires
+}
+
+// mac returns the hex HMAC-SHA256 of the payload.
+func (s *StepUp) mac(payload string) string {
+	h := hmac.New(sha256.New, s.Secret)
+	h.Write([]byte(payload))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// stale reports whether a record no longer guards against anything: a totp
+// record whose last accepted step has fallen out of every window Verify
+// could check. Its failure count and drift estimate go with it.
+func (s *StepUp) stale(r otp.Record) bool {
+	if r.Key.Method != "totp" || r.Key.Period < 1 {
+		return false
+	}
+	reach := int64(s.Auth.Window)
+	if s.Auth.Drift != nil {
+		reach += int64(s.Auth.Drift.MaxDrift)
+	}
+	return r.LastStep < s.now().Unix()/int64(r.Key.Period)-reach
+}
+
+// sweepEvery is how often lookupStore drops stale records.
+const sweepEvery = time.Minute
+
+// lookupStore is an otp.KeyStore fetching keys through a callback and
+// keeping only the state that guards against replays, in memory. Stale
+// records are swept out as others are saved, so the state doesn't grow with
+// every user ever seen.
+type lookupStore struct {
+	key   func(user string) (otp.Key, error)
+	stale func(otp.Record) bool
+	now   func() time.Time
+	mu    sync.Mutex
+	state map[string]otp.Record
+	swept time.Time
+}
+
+func (l *lookupStore) Load(user string) (otp.Record, error) {
+	k, err := l.key(user)
+	if err != nil {
+		return otp.Record{}, err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	r, ok := l.state[user]
+	if ok && k.Method == "hotp" && r.Key.Counter > k.Counter {
+		k.Counter = r.Key.Counter
+	}
+	r.Key = k
+	return r, nil
+}
+
+func (l *lookupStore) Save(user string, r otp.Record) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.state[user] = r
+
+	if now := l.now(); now.Sub(l.swept) >= sweepEvery {
+		for u, r := range l.state {
+			if l.stale(r) {
+				delete(l.state, u)
+			}
+		}
+		l.swept = now
+	}
+	return nil
+}
+
+func (l *lookupStore) Delete(user string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.state, user)
+	return nil
+}
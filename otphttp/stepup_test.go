@@ -0,0 +1,206 @@
+package otphttp
+
+import (
+	"crypto/sha1"
+	"errors"
+	"github.com/tristanwietsma/otp"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testNow = time.Unix(1433160000, 0)
+
+var testKey = otp.Key{Method: "totp", Label: "alice", Secret32: "MFRGGZDFMZTWQ2LK", Algo: sha1.New, Digits: 6, Period: 30}
+
+// newTestStepUp returns middleware for the user named in the X-User header,
+// with a clock that moves when now is changed.
+func newTestStepUp(now *time.Time) *StepUp {
+	s := NewStepUp([]byte("0123456789abcdef0123456789abcdef"),
+		func(r *http.Request) (string, error) {
+			return r.Header.Get("X-User"), nil
+		},
+		func(user string) (otp.Key, error) {
+			if user != "alice" && user != "bob" {
+				return otp.Key{}, otp.ErrUnknownUser
+			}
+			return testKey, nil
+		})
+	s.Now = func() time.Time { return *now }
+	return s
+}
+
+var secretPage = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("secret"))
+})
+
+func code(t *testing.T, at time.Time) string {
+	c, err := testKey.GetCode(at.Unix() / 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func serve(h http.Handler, user, code string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("GET", "/admin", nil)
+	r.Header.Set("X-User", user)
+	if code != "" {
+		r.Header.Set("X-OTP-Code", code)
+	}
+	for _, c := range cookies {
+		r.AddCookie(c)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestStepUpHeader(t *testing.T) {
+	now := testNow
+	h := newTestStepUp(&now).Require(secretPage)
+
+	w := serve(h, "alice", "")
+	if w.Code != http.StatusUnauthorized || w.Header().Get("WWW-Authenticate") == "" {
+		t.Errorf("Expected a challenge without a code, got %v", w.Code)
+	}
+	if w := serve(h, "alice", "000000"); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected a wrong code to be refused, got %v", w.Code)
+	}
+
+	w = serve(h, "alice", code(t, now))
+	if w.Code != http.StatusOK || w.Body.String() != "secret" {
+		t.Fatalf("Expected the page for a valid code, got %v %q", w.Code, w.Body)
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || !cookies[0].HttpOnly || !cookies[0].Secure || cookies[0].SameSite != http.SameSiteStrictMode {
+		t.Fatalf("Expected a step-up cookie, got %v", cookies)
+	}
+
+	if w := serve(h, "alice", code(t, now)); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected a replayed code to be refused, got %v", w.Code)
+	}
+	if w := serve(h, "alice", "", cookies[0]); w.Code != http.StatusOK {
+		t.Errorf("Expected the cookie to be honored, got %v", w.Code)
+	}
+	if w := serve(h, "bob", "", cookies[0]); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected the cookie to be bound to alice, got %v", w.Code)
+	}
+
+	now = now.Add(15 * time.Minute)
+	if w := serve(h, "alice", "", cookies[0]); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected the cookie to expire, got %v", w.Code)
+	}
+}
+
+func TestStepUpPrunesReplayState(t *testing.T) {
+	now := testNow
+	s := newTestStepUp(&now)
+	h := s.Require(secretPage)
+	store := s.Auth.Store.(*lookupStore)
+
+	serve(h, "alice", code(t, now))
+	serve(h, "bob", code(t, now))
+	if len(store.state) != 2 {
+		t.Fatalf("Expected state for 2 users, got %v", len(store.state))
+	}
+
+	// once the codes are out of the window, replays are impossible anyway
+	now = now.Add(2 * time.Minute)
+	serve(h, "alice", code(t, now))
+	if _, ok := store.state["bob"]; ok || len(store.state) != 1 {
+		t.Errorf("Expected bob's stale state to be pruned, got %v", store.state)
+	}
+	if w := serve(h, "alice", code(t, now)); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected a replayed code to still be refused, got %v", w.Code)
+	}
+}
+
+func TestStepUpForm(t *testing.T) {
+	now := testNow
+	h := newTestStepUp(&now).Require(secretPage)
+
+	form := url.Values{"otp": {code(t, now)}}
+	r := httptest.NewRequest("POST", "/admin", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.Header.Set("X-User", "alice")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected a code from the form to be accepted, got %v", w.Code)
+	}
+}
+
+func TestStepUpCookieTampering(t *testing.T) {
+	now := testNow
+	s := newTestStepUp(&now)
+	h := s.Require(secretPage)
+
+	value := s.sign("alice", now.Add(time.Hour))
+	forged := []string{
+		"",
+		"garbage",
+		value[:len(value)-1] + "0",
+		strings.Replace(value, "YWxpY2U", "Ym9i", 1),
+		NewStepUp([]byte("another secret"), s.User, nil).sign("alice", now.Add(time.Hour)),
+	}
+	for _, v := range forged {
+		if w := serve(h, "alice", "", &http.Cookie{Name: "otp_stepup", Value: v}); w.Code != http.StatusUnauthorized {
+			t.Errorf("Expected cookie %q to be refused, got %v", v, w.Code)
+		}
+	}
+	if w := serve(h, "alice", "", &http.Cookie{Name: "otp_stepup", Value: value}); w.Code != http.StatusOK {
+		t.Errorf("Expected a genuine cookie to be honored, got %v", w.Code)
+	}
+}
+
+func TestStepUpErrors(t *testing.T) {
+	now := testNow
+	s := newTestStepUp(&now)
+	h := s.Require(secretPage)
+
+	if w := serve(h, "", "123456"); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected a missing user to be refused, got %v", w.Code)
+	}
+	if w := serve(h, "mallory", "123456"); w.Code != http.StatusForbidden {
+		t.Errorf("Expected a user without a key to be forbidden, got %v", w.Code)
+	}
+
+	s.User = func(r *http.Request) (string, error) { return "", errors.New("no session") }
+	if w := serve(h, "alice", "123456"); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected a failed sign-in to be refused, got %v", w.Code)
+	}
+}
+
+func TestStepUpThrottle(t *testing.T) {
+	now := testNow
+	s := newTestStepUp(&now)
+	s.Auth.Throttle = otp.NewThrottle(otp.NewMemoryAttemptStore())
+	s.Auth.Throttle.Now = s.Now
+	h := s.Require(secretPage)
+
+	serve(h, "alice", "000000")
+	w := serve(h, "alice", code(t, now))
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "1" {
+		t.Errorf("Expected a backoff, got %v %v", w.Code, w.Header())
+	}
+
+	now = now.Add(time.Second)
+	if w := serve(h, "alice", code(t, now)); w.Code != http.StatusOK {
+		t.Errorf("Expected the code to be accepted after the backoff, got %v", w.Code)
+	}
+}
+
+func TestStepUpChallenge(t *testing.T) {
+	now := testNow
+	s := newTestStepUp(&now)
+	s.Challenge = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/otp", http.StatusSeeOther)
+	})
+	if w := serve(s.Require(secretPage), "alice", ""); w.Code != http.StatusSeeOther {
+		t.Errorf("Expected the custom challenge, got %v", w.Code)
+	}
+}
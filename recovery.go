@@ -0,0 +1,151 @@
+package otp
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"math/big"
+	"strings"
+)
+
+// recoveryAlphabet leaves out characters easily mistaken for one another,
+// such as 0 and o or 1 and l.
+const recoveryAlphabet = "23456789abcdefghjkmnpqrstuvwxyz"
+
+// recoveryLength is the number of characters in a generated code, about
+// 50 bits of randomness. Codes are shown in two hyphenated halves.
+const recoveryLength = 10
+
+// ErrNoRecoveryCodes is returned when asked to make an empty set of codes.
+var ErrNoRecoveryCodes = errors.New("no recovery codes")
+
+// RecoveryCode is the salted SHA-256 hash of one recovery code. Codes are
+// random enough that a slow hash adds nothing.
+type RecoveryCode struct {
+	Salt []byte `json:"salt"`
+	Hash []byte `json:"hash"`
+	Used bool   `json:"used"`
+}
+
+// RecoveryCodes is a set of single-use codes for signing in without a key,
+// such as after losing a phone. Only hashes are kept, so the set can be
+// stored alongside the key without revealing the codes. It is not safe for
+// concurrent use.
+type RecoveryCodes struct {
+	Codes []RecoveryCode `json:"codes"`
+}
+
+// NewRecoveryCodes generates n codes, returning them for showing to the
+// user once along with the set of their hashes.
+func NewRecoveryCodes(n int) (*RecoveryCodes, []string, error) {
+	r := &RecoveryCodes{}
+	codes, err := r.Regenerate(n)
+	if err != nil {
+		return nil, nil, err
+	}
+	return r, codes, nil
+}
+
+// HashRecoveryCodes returns the set of hashes for existing codes, such as
+// those issued by a service.
+func HashRecoveryCodes(codes []string) (*RecoveryCodes, error) {
+	if len(codes) == 0 {
+		return nil, ErrNoRecoveryCodes
+	}
+	r := &RecoveryCodes{}
+	for _, code := range codes {
+		salt := make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, err
+		}
+		r.Codes = append(r.Codes, RecoveryCode{Salt: salt, Hash: hashRecoveryCode(salt, code)})
+	}
+	return r, nil
+}
+
+// Regenerate replaces the set with n new codes, which it returns.
+func (r *RecoveryCodes) Regenerate(n int) ([]string, error) {
+	if n < 1 {
+		return nil, ErrNoRecoveryCodes
+	}
+	codes := []string{}
+	for i := 0; i < n; i++ {
+		code, err := newRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+
+	fresh, err := HashRecoveryCodes(codes)
+	if err != nil {
+		return nil, err
+	}
+	*r = *fresh
+	return codes, nil
+}
+
+// Consume marks the code as used and returns its index in the set, or false
+// if it isn't an unused code. Every code is hashed and compared in constant
+// time, so the time taken reveals nothing about which, if any, matched.
+func (r *RecoveryCodes) Consume(code string) (int, bool) {
+	match := -1
+	for i, c := range r.Codes {
+		eq := subtle.ConstantTimeCompare(hashRecoveryCode(c.Salt, code), c.Hash)
+		unused := subtle.ConstantTimeByteEq(boolByte(c.Used), 0)
+		match = subtle.ConstantTimeSelect(eq&unused, i, match)
+	}
+	if match < 0 {
+		return 0, false
+	}
+	r.Codes[match].Used = true
+	return match, true
+}
+
+// Remaining returns the number of unused codes.
+func (r *RecoveryCodes) Remaining() int {
+	n := 0
+	for _, c := range r.Codes {
+		if !c.Used {
+			n++
+		}
+	}
+	return n
+}
+
+// newRecoveryCode returns a random code formatted as two hyphenated halves.
+func newRecoveryCode() (string, error) {
+	max := big.NewInt(int64(len(recoveryAlphabet)))
+	code := make([]byte, 0, recoveryLength+1)
+	for i := 0; i < recoveryLength; i++ {
+		if i == recoveryLength/2 {
+			code = append(code, '-')
+		}
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		code = append(code, recoveryAlphabet[n.Int64()])
+	}
+	return string(code), nil
+}
+
+// hashRecoveryCode hashes the salt and the code, ignoring case, spaces and
+// hyphens.
+func hashRecoveryCode(salt []byte, code string) []byte {
+	code = strings.ToLower(strings.Join(strings.FieldsFunc(code, func(r rune) bool {
+		return r == '-' || r == ' '
+	}), ""))
+	h := sha256.New()
+	h.Write(salt)
+	h.Write([]byte(code))
+	return h.Sum(nil)
+}
+
+func boolByte(b bool) uint8 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,101 @@
+package otp
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestNewRecoveryCodes(t *testing.T) {
+	r, codes, err := NewRecoveryCodes(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != 10 || len(r.Codes) != 10 || r.Remaining() != 10 {
+		t.Fatalf("Expected 10 codes, got %v and %v hashes", codes, len(r.Codes))
+	}
+
+	format := regexp.MustCompile("^[" + recoveryAlphabet + "]{5}-[" + recoveryAlphabet + "]{5}$")
+	seen := map[string]bool{}
+	for _, code := range codes {
+		if !format.MatchString(code) {
+			t.Errorf("Unexpected code format %q", code)
+		}
+		if seen[code] {
+			t.Errorf("Duplicate code %q", code)
+		}
+		seen[code] = true
+	}
+
+	data, _ := json.Marshal(r)
+	for _, code := range codes {
+		if strings.Contains(string(data), code) || strings.Contains(string(data), strings.Replace(code, "-", "", 1)) {
+			t.Errorf("Code %q stored in plain text", code)
+		}
+	}
+
+	if _, _, err := NewRecoveryCodes(0); err != ErrNoRecoveryCodes {
+		t.Errorf("Expected ErrNoRecoveryCodes, got %v", err)
+	}
+}
+
+func TestConsumeRecoveryCode(t *testing.T) {
+	r, codes, err := NewRecoveryCodes(5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if i, ok := r.Consume(codes[3]); !ok || i != 3 {
+		t.Errorf("Expected code 3 to be consumed, got %v %v", i, ok)
+	}
+	if _, ok := r.Consume(codes[3]); ok {
+		t.Error("Expected a used code to be refused")
+	}
+	if r.Remaining() != 4 || !r.Codes[3].Used {
+		t.Errorf("Expected 4 codes left, got %v", r.Remaining())
+	}
+
+	loose := " " + strings.ToUpper(strings.Replace(codes[0], "-", " ", 1)) + " "
+	if i, ok := r.Consume(loose); !ok || i != 0 {
+		t.Errorf("Expected %q to match code 0, got %v %v", loose, i, ok)
+	}
+	if _, ok := r.Consume("abcde-fghjk"); ok {
+		t.Error("Expected an unknown code to be refused")
+	}
+}
+
+func TestRegenerateRecoveryCodes(t *testing.T) {
+	r, old, err := NewRecoveryCodes(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Consume(old[0])
+
+	codes, err := r.Regenerate(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Remaining() != 4 || len(codes) != 4 {
+		t.Errorf("Expected 4 fresh codes, got %v", r.Remaining())
+	}
+	if _, ok := r.Consume(old[1]); ok {
+		t.Error("Expected old codes to be invalidated")
+	}
+}
+
+func TestHashRecoveryCodes(t *testing.T) {
+	r, err := HashRecoveryCodes([]string{"1234-5678", "abcd-efgh"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(r.Codes[0].Salt) == string(r.Codes[1].Salt) {
+		t.Error("Expected each code to have its own salt")
+	}
+	if i, ok := r.Consume("ABCDEFGH"); !ok || i != 1 {
+		t.Errorf("Expected the second code to match, got %v %v", i, ok)
+	}
+	if _, err := HashRecoveryCodes(nil); err != ErrNoRecoveryCodes {
+		t.Errorf("Expected ErrNoRecoveryCodes, got %v", err)
+	}
+}
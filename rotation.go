@@ -0,0 +1,158 @@
+package otp
+
+import (
+	"errors"
+	"github.com/tristanwietsma/rsc/qr"
+	"sync"
+	"time"
+)
+
+// ErrRotating is returned by Rotate while the previous key is still
+// accepted.
+var ErrRotating = errors.New("previous key is still accepted")
+
+// Match reports which key of a Rotation accepted a code.
+type Match int
+
+const (
+	NoMatch       Match = iota // Neither key accepted the code.
+	CurrentMatch               // The current key accepted the code.
+	PreviousMatch              // The previous key accepted the code during its grace period.
+)
+
+func (m Match) String() string {
+	switch m {
+	case CurrentMatch:
+		return "current"
+	case PreviousMatch:
+		return "previous"
+	}
+	return "none"
+}
+
+// RotatedKey is a key of a Rotation and the times it is accepted between.
+type RotatedKey struct {
+	Key       Key
+	NotBefore time.Time // When the key starts to be accepted. Zero means always.
+	NotAfter  time.Time // When the key stops being accepted. Zero means never.
+}
+
+// Active reports whether the key is accepted at the time.
+func (r RotatedKey) Active(t time.Time) bool {
+	return (r.NotBefore.IsZero() || !t.Before(r.NotBefore)) &&
+		(r.NotAfter.IsZero() || t.Before(r.NotAfter))
+}
+
+// Rotation replaces a key that may be compromised with a new secret while
+// still accepting codes from the old one for a grace period, so users can
+// re-enroll without being locked out. Its methods are safe for concurrent
+// use, but Verify changes the rotation, so save it after each call.
+type Rotation struct {
+	Current  RotatedKey
+	Previous *RotatedKey   // The key being replaced, or nil.
+	Tracker  *DriftTracker // Optional recentring of the totp window on Drift.
+	Drift    float64       // Smoothed estimate, in steps, of how far the user's totp clock is ahead.
+
+	mu sync.Mutex
+}
+
+// NewRotation returns a rotation whose current key is k, accepted from now
+// on.
+func NewRotation(k Key) *Rotation {
+	return &Rotation{Current: RotatedKey{Key: k}}
+}
+
+// Rotate makes a copy of the current key with the new secret current from
+// the time at, accepting the old key until grace has passed after that.
+// Rotating again before the previous key has expired returns ErrRotating,
+// since its users would be locked out.
+//
+// Example:
+//      secret, _ := NewSecret32()
+//      err := r.Rotate(secret, time.Now(), 7*24*time.Hour)
+//      uri := r.EnrollmentURI()
+func (r *Rotation) Rotate(secret32 string, at time.Time, grace time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.Previous != nil && r.Previous.Active(at) {
+		return ErrRotating
+	}
+
+	next := r.Current.Key
+	next.Secret32 = secret32
+	if err := next.Validate(); err != nil {
+		return err
+	}
+
+	prev := r.Current
+	prev.NotAfter = at.Add(grace)
+	r.Previous = &prev
+	r.Current = RotatedKey{Key: next, NotBefore: at}
+	return nil
+}
+
+// Prune forgets the previous key once it has expired at time t.
+func (r *Rotation) Prune(t time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.Previous != nil && !r.Previous.NotAfter.IsZero() && !t.Before(r.Previous.NotAfter) {
+		r.Previous = nil
+	}
+}
+
+// Verify checks the code at time t against the active keys, the current one
+// first, in the window an Authenticator uses: a totp code may be from up to
+// window steps either side of t, recentred on Drift with a Tracker, and an
+// hotp code from up to window counters past the key's. It reports which key
+// matched and the step or counter it matched at.
+//
+// An hotp match moves the matching key's counter past it, and a totp match
+// moves Drift with a Tracker; save the rotation afterwards, or the next
+// Verify will start from the old counter. Replayed totp codes are not
+// detected; record the step, or use an Authenticator, for that.
+func (r *Rotation) Verify(code string, t time.Time, window int) (Match, int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	keys := []struct {
+		key   *RotatedKey
+		match Match
+	}{{&r.Current, CurrentMatch}, {r.Previous, PreviousMatch}}
+
+	rec := Record{Drift: r.Drift}
+	offset := 0
+	if r.Tracker != nil {
+		offset = r.Tracker.Offset(rec)
+	}
+	for _, k := range keys {
+		if k.key == nil || !k.key.Active(t) {
+			continue
+		}
+		iv, step, ok, err := matchWindow(k.key.Key, code, t, window, offset)
+		if err != nil {
+			return NoMatch, 0, err
+		}
+		if !ok {
+			continue
+		}
+		if k.key.Key.Method == "hotp" {
+			k.key.Key.Counter = int(iv) + 1
+		} else if r.Tracker != nil {
+			r.Tracker.Observe(&rec, int(iv-step))
+			r.Drift = rec.Drift
+		}
+		return k.match, iv, nil
+	}
+	return NoMatch, 0, nil
+}
+
+// EnrollmentURI returns the otpauth URI of the current key, for users to
+// add to their authenticators.
+func (r *Rotation) EnrollmentURI() string {
+	return r.Current.Key.ToURI()
+}
+
+// EnrollmentQR returns the QR code of the current key's otpauth URI.
+func (r *Rotation) EnrollmentQR() (*qr.Code, error) {
+	return r.Current.Key.QrCode()
+}
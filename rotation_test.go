@@ -0,0 +1,145 @@
+package otp
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRotation(t *testing.T) {
+	old := totpKey()
+	r := NewRotation(old)
+	at := authNow
+	step := at.Unix() / 30
+
+	if m, iv, err := r.Verify(mustCode(t, old, step), at, 1); m != CurrentMatch || iv != step || err != nil {
+		t.Fatalf("Expected the current key to match, got %v %v %v", m, iv, err)
+	}
+
+	if err := r.Rotate("NAR5XTDD3EQU22YU", at, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	next := r.Current.Key
+	if next.Label != old.Label || next.Period != old.Period || next.Secret32 != "NAR5XTDD3EQU22YU" {
+		t.Errorf("Expected a copy of the key with the new secret, got %+v", next)
+	}
+
+	tests := []struct {
+		key  Key
+		at   time.Time
+		want Match
+	}{
+		{next, at, CurrentMatch},
+		{old, at, PreviousMatch},
+		{old, at.Add(59 * time.Minute), PreviousMatch},
+		{old, at.Add(time.Hour), NoMatch},
+		{next, at.Add(-time.Minute), NoMatch},
+	}
+	for _, tt := range tests {
+		code := mustCode(t, tt.key, tt.at.Unix()/30)
+		if m, _, err := r.Verify(code, tt.at, 1); m != tt.want || err != nil {
+			t.Errorf("At %v expected %v, got %v %v", tt.at, tt.want, m, err)
+		}
+	}
+
+	if err := r.Rotate("MFRGGZDFMZTWQ2LK", at.Add(time.Minute), time.Hour); err != ErrRotating {
+		t.Errorf("Expected ErrRotating during the grace period, got %v", err)
+	}
+	r.Prune(at.Add(time.Minute))
+	if r.Previous == nil {
+		t.Error("Expected the previous key to be kept during the grace period")
+	}
+	r.Prune(at.Add(time.Hour))
+	if r.Previous != nil {
+		t.Error("Expected the expired key to be pruned")
+	}
+}
+
+func TestRotationErrors(t *testing.T) {
+	r := NewRotation(totpKey())
+	var verr *ValidationError
+	if err := r.Rotate("abc123", authNow, time.Hour); !errors.As(err, &verr) || r.Previous != nil {
+		t.Errorf("Expected a bad secret to be rejected, got %v", err)
+	}
+
+	r.Current.Key.Method = "xotp"
+	if _, _, err := r.Verify("123456", authNow, 1); err != ErrInvalidMethod {
+		t.Errorf("Expected ErrInvalidMethod, got %v", err)
+	}
+}
+
+func TestRotationHOTP(t *testing.T) {
+	old := hotpKey()
+	r := NewRotation(old)
+	if err := r.Rotate("NAR5XTDD3EQU22YU", authNow, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	m, iv, err := r.Verify(mustCode(t, old, 7), authNow, 5)
+	if m != PreviousMatch || iv != 7 || err != nil {
+		t.Fatalf("Expected the previous key to match counter 7, got %v %v %v", m, iv, err)
+	}
+	if r.Previous.Key.Counter != 8 || r.Current.Key.Counter != 5 {
+		t.Errorf("Expected only the matching key's counter to move, got %v %v", r.Previous.Key.Counter, r.Current.Key.Counter)
+	}
+}
+
+func TestRotationDrift(t *testing.T) {
+	k := totpKey()
+	r := NewRotation(k)
+	r.Tracker = NewDriftTracker()
+	step := authNow.Unix() / 30
+
+	// a clock running a step slow moves the window back
+	for i := 0; i < 10; i++ {
+		m, _, err := r.Verify(mustCode(t, k, step-1), authNow, 1)
+		if m != CurrentMatch || err != nil {
+			t.Fatalf("Attempt %v: expected a match, got %v %v", i, m, err)
+		}
+	}
+	if r.Drift > -0.5 {
+		t.Errorf("Expected the drift estimate to follow the clock, got %v", r.Drift)
+	}
+	if m, _, _ := r.Verify(mustCode(t, k, step-2), authNow, 1); m != CurrentMatch {
+		t.Error("Expected the window to be recentred on the drift")
+	}
+}
+
+func TestRotationConcurrent(t *testing.T) {
+	k := hotpKey()
+	r := NewRotation(k)
+	code := mustCode(t, k, int64(k.Counter))
+
+	matches := make(chan Match)
+	for i := 0; i < 10; i++ {
+		go func() {
+			m, _, _ := r.Verify(code, authNow, 5)
+			matches <- m
+		}()
+	}
+	n := 0
+	for i := 0; i < 10; i++ {
+		if <-matches == CurrentMatch {
+			n++
+		}
+	}
+	if n != 1 || r.Current.Key.Counter != k.Counter+1 {
+		t.Errorf("Expected one match and the counter moved once, got %v and %v", n, r.Current.Key.Counter)
+	}
+}
+
+func TestRotationEnrollment(t *testing.T) {
+	r := NewRotation(totpKey())
+	if err := r.Rotate("NAR5XTDD3EQU22YU", authNow, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if uri := r.EnrollmentURI(); uri != r.Current.Key.ToURI() {
+		t.Errorf("Expected the current key's URI, got %v", uri)
+	}
+	if _, err := r.EnrollmentQR(); err != nil {
+		t.Error(err)
+	}
+	if CurrentMatch.String() != "current" || NoMatch.String() != "none" {
+		t.Error("Unexpected Match names")
+	}
+}
@@ -0,0 +1,117 @@
+package otp
+
+import (
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+// Errors reported for secrets that aren't valid in the encodings other than
+// Base32.
+var (
+	ErrInvalidHex    = errors.New("secret is not valid hex")
+	ErrInvalidBase64 = errors.New("secret is not valid Base64")
+)
+
+// Secret is the decoded HMAC key of a one-time password. Keys carry it
+// Base32-encoded, as in otpauth URIs, but seeds from PSKC files, YubiKey
+// CSVs and some vendors are hex or Base64; decoding them into a Secret
+// checks them in one place.
+//
+// Example:
+//      s, err := SecretFromHex("3132333435363738393031323334353637383930")
+//      k, err := NewTOTPKey("label", s.Base32(), "issuer", sha1.New, 6, 30)
+type Secret []byte
+
+// SecretFromBase32 decodes a Base32 secret as people and password managers
+// write it: in either case, with or without padding, and with spaces
+// between groups of letters. Base32 returns it in the form kept in Secret32.
+func SecretFromBase32(s string) (Secret, error) {
+	s = strings.TrimRight(strings.ToUpper(strings.Replace(s, " ", "", -1)), "=")
+	if s == "" {
+		return nil, ErrMissingSecret
+	}
+	b, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidSecret
+	}
+	return Secret(b), nil
+}
+
+// secretFromSecret32 decodes a secret in the padded upper case form kept in
+// Secret32, which keys are validated against.
+func secretFromSecret32(s string) (Secret, error) {
+	if s == "" {
+		return nil, ErrMissingSecret
+	}
+	b, err := base32.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidSecret
+	}
+	return Secret(b), nil
+}
+
+// SecretFromHex decodes a hex secret in either case. Spaces are ignored.
+func SecretFromHex(s string) (Secret, error) {
+	s = strings.Replace(s, " ", "", -1)
+	if s == "" {
+		return nil, ErrMissingSecret
+	}
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidHex
+	}
+	return Secret(b), nil
+}
+
+// SecretFromBase64 decodes a standard or URL-safe Base64 secret, with or
+// without padding.
+func SecretFromBase64(s string) (Secret, error) {
+	if s == "" {
+		return nil, ErrMissingSecret
+	}
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		if b, err := enc.DecodeString(s); err == nil {
+			return Secret(b), nil
+		}
+	}
+	return nil, ErrInvalidBase64
+}
+
+// SecretFromBytes returns a copy of the raw secret.
+func SecretFromBytes(b []byte) (Secret, error) {
+	if len(b) == 0 {
+		return nil, ErrMissingSecret
+	}
+	return append(Secret(nil), b...), nil
+}
+
+// Base32 returns the secret padded and Base32-encoded, for Secret32 and
+// otpauth URIs.
+func (s Secret) Base32() string {
+	return base32.StdEncoding.EncodeToString(s)
+}
+
+// Hex returns the secret in lowercase hex.
+func (s Secret) Hex() string {
+	return hex.EncodeToString(s)
+}
+
+// Base64 returns the secret in padded standard Base64.
+func (s Secret) Base64() string {
+	return base64.StdEncoding.EncodeToString(s)
+}
+
+// Secret returns the key's decoded secret. Secret32 must be in the padded
+// upper case form SetSecret leaves it in; decode other forms with
+// SecretFromBase32.
+func (k Key) Secret() (Secret, error) {
+	return secretFromSecret32(k.Secret32)
+}
+
+// SetSecret sets the key's secret, encoding it into Secret32.
+func (k *Key) SetSecret(s Secret) {
+	k.Secret32 = s.Base32()
+}
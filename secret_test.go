@@ -0,0 +1,109 @@
+package otp
+
+import (
+	"crypto/sha1"
+	"testing"
+)
+
+func TestSecretEncodings(t *testing.T) {
+	// the RFC 4226 test secret, "12345678901234567890", in each encoding
+	tests := []struct {
+		name   string
+		decode func(string) (Secret, error)
+		in     string
+	}{
+		{"base32", SecretFromBase32, rfcSecret},
+		{"base32 unpadded", SecretFromBase32, "gezd gnbv gy3t qojq gezd gnbv gy3t qojq"},
+		{"hex", SecretFromHex, "3132333435363738393031323334353637383930"},
+		{"hex spaced", SecretFromHex, "31323334 35363738 39303132 33343536 37383930"},
+		{"base64", SecretFromBase64, "MTIzNDU2Nzg5MDEyMzQ1Njc4OTA="},
+		{"base64 raw", SecretFromBase64, "MTIzNDU2Nzg5MDEyMzQ1Njc4OTA"},
+	}
+	for _, tt := range tests {
+		s, err := tt.decode(tt.in)
+		if err != nil {
+			t.Errorf("%v: %v", tt.name, err)
+			continue
+		}
+		if string(s) != "12345678901234567890" {
+			t.Errorf("%v: got %q", tt.name, s)
+		}
+		if s.Base32() != rfcSecret {
+			t.Errorf("%v: expected %v, got %v", tt.name, rfcSecret, s.Base32())
+		}
+	}
+
+	s, _ := SecretFromBytes([]byte("12345678901234567890"))
+	if s.Hex() != "3132333435363738393031323334353637383930" || s.Base64() != "MTIzNDU2Nzg5MDEyMzQ1Njc4OTA=" {
+		t.Errorf("Unexpected encodings %v %v", s.Hex(), s.Base64())
+	}
+}
+
+func TestSecretErrors(t *testing.T) {
+	tests := []struct {
+		decode func(string) (Secret, error)
+		in     string
+		want   error
+	}{
+		{SecretFromBase32, "", ErrMissingSecret},
+		{SecretFromBase32, "abc123", ErrInvalidSecret},
+		{SecretFromBase32, " == ", ErrMissingSecret},
+		{SecretFromHex, " ", ErrMissingSecret},
+		{SecretFromHex, "31g2", ErrInvalidHex},
+		{SecretFromHex, "313", ErrInvalidHex},
+		{SecretFromBase64, "", ErrMissingSecret},
+		{SecretFromBase64, "MTIz*", ErrInvalidBase64},
+	}
+	for _, tt := range tests {
+		if _, err := tt.decode(tt.in); err != tt.want {
+			t.Errorf("%q: expected %v, got %v", tt.in, tt.want, err)
+		}
+	}
+	if _, err := SecretFromBytes(nil); err != ErrMissingSecret {
+		t.Errorf("Expected ErrMissingSecret, got %v", err)
+	}
+}
+
+func TestSecretCopies(t *testing.T) {
+	b := []byte("12345678901234567890")
+	s, _ := SecretFromBytes(b)
+	b[0] = 'x'
+	if s[0] != '1' {
+		t.Error("Expected SecretFromBytes to copy its argument")
+	}
+}
+
+func TestSecretGenerator(t *testing.T) {
+	s, _ := SecretFromHex("3132333435363738393031323334353637383930")
+	g, err := s.Generator(sha1.New, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code := g.Code(0); code != "755224" {
+		t.Errorf("Expected the RFC 4226 code 755224, got %v", code)
+	}
+}
+
+func TestSecretFromBase32Lenient(t *testing.T) {
+	s, err := SecretFromBase32("nar5 xtdd 3equ 22yu ge")
+	if err != nil || s.Base32() != "NAR5XTDD3EQU22YUGE======" {
+		t.Errorf("Expected the padded upper case secret, got %v %v", s.Base32(), err)
+	}
+	// keys keep the strict form
+	if _, err := (Key{Secret32: "nar5xtdd3equ22yu"}).Secret(); err != ErrInvalidSecret {
+		t.Errorf("Expected ErrInvalidSecret for a lower case Secret32, got %v", err)
+	}
+}
+
+func TestKeySecret(t *testing.T) {
+	k := totpKey()
+	s, _ := SecretFromBase64("MTIzNDU2Nzg5MDEyMzQ1Njc4OTA=")
+	k.SetSecret(s)
+	if k.Secret32 != rfcSecret {
+		t.Errorf("Expected %v, got %v", rfcSecret, k.Secret32)
+	}
+	got, err := k.Secret()
+	if err != nil || string(got) != "12345678901234567890" {
+		t.Errorf("Expected the decoded secret, got %q %v", got, err)
+	}
+}
@@ -0,0 +1,181 @@
+package otp
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// ErrUnknownUser is returned by a KeyStore for a user with no record.
+var ErrUnknownUser = errors.New("unknown user")
+
+// Record is the state kept for a user of an Authenticator.
+type Record struct {
+	Key      Key     // The user's key. For hotp, Counter is the next counter expected.
+	LastStep int64   // Last totp step accepted, so codes can't be replayed.
+	Failures int     // Failed verifications since the last success.
+	Drift    float64 // Smoothed estimate, in steps, of how far the user's totp clock is ahead.
+}
+
+// KeyStore loads and saves the records of an Authenticator's users.
+// Implementations must be safe for concurrent use; the Authenticator
+// serializes access to any one user.
+type KeyStore interface {
+	Load(user string) (Record, error) // Returns ErrUnknownUser if there is no record.
+	Save(user string, r Record) error
+	Delete(user string) error
+}
+
+// MemoryStore is a KeyStore holding records in memory.
+type MemoryStore struct {
+	mu      sync.RWMutex
+	records map[string]Record
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{records: map[string]Record{}}
+}
+
+// Load returns the user's record.
+func (s *MemoryStore) Load(user string) (Record, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	r, ok := s.records[user]
+	if !ok {
+		return Record{}, ErrUnknownUser
+	}
+	return r, nil
+}
+
+// Save stores the user's record.
+func (s *MemoryStore) Save(user string, r Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[user] = r
+	return nil
+}
+
+// Delete removes the user's record, if any.
+func (s *MemoryStore) Delete(user string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, user)
+	return nil
+}
+
+// FileStore is a KeyStore keeping records in a JSON file, readable only by
+// its owner. Keys are stored as otpauth URIs. Each save rewrites the file
+// through a temporary file, so a crash never leaves it half-written. Only
+// one process should use a file at a time.
+type FileStore struct {
+	Path string
+	mu   sync.Mutex
+}
+
+// fileRecord is the JSON form of a Record.
+type fileRecord struct {
+	URI      string  `json:"uri"`
+	LastStep int64   `json:"last_step"`
+	Failures int     `json:"failures"`
+	Drift    float64 `json:"drift,omitempty"`
+}
+
+// NewFileStore returns a FileStore for the file at path, which is created
+// on the first save.
+func NewFileStore(path string) *FileStore {
+	return &FileStore{Path: path}
+}
+
+// Load returns the user's record.
+func (s *FileStore) Load(user string) (Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	records, err := s.read()
+	if err != nil {
+		return Record{}, err
+	}
+	fr, ok := records[user]
+	if !ok {
+		return Record{}, ErrUnknownUser
+	}
+
+	r := Record{LastStep: fr.LastStep, Failures: fr.Failures, Drift: fr.Drift}
+	if err := r.Key.FromURI(fr.URI); err != nil {
+		return Record{}, err
+	}
+	return r, nil
+}
+
+// Save stores the user's record.
+func (s *FileStore) Save(user string, r Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	records, err := s.read()
+	if err != nil {
+		return err
+	}
+	records[user] = fileRecord{r.Key.ToURI(), r.LastStep, r.Failures, r.Drift}
+	return s.write(records)
+}
+
+// Delete removes the user's record, if any.
+func (s *FileStore) Delete(user string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	records, err := s.read()
+	if err != nil {
+		return err
+	}
+	if _, ok := records[user]; !ok {
+		return nil
+	}
+	delete(records, user)
+	return s.write(records)
+}
+
+// read returns the records in the file; a missing file has none.
+func (s *FileStore) read() (map[string]fileRecord, error) {
+	records := map[string]fileRecord{}
+	data, err := os.ReadFile(s.Path)
+	if os.IsNotExist(err) {
+		return records, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// write replaces the file with the records.
+func (s *FileStore) write(records map[string]fileRecord) error {
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(s.Path), "."+filepath.Base(s.Path)+".*")
+	if err != nil {
+		return err
+	}
+	// CreateTemp makes the file readable only by its owner
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), s.Path)
+}
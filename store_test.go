@@ -0,0 +1,87 @@
+package otp
+
+import (
+	"crypto/sha256"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var storeKey = Key{
+	Method:   "hotp",
+	Label:    "alice",
+	Secret32: "MFRGGZDFMZTWQ2LK",
+	Issuer:   "issuer",
+	Algo:     sha256.New,
+	Digits:   6,
+	Counter:  7,
+}
+
+func testStore(t *testing.T, s KeyStore) {
+	if _, err := s.Load("alice"); err != ErrUnknownUser {
+		t.Errorf("Expected ErrUnknownUser, got %v", err)
+	}
+
+	want := Record{Key: storeKey, LastStep: 42, Failures: 3, Drift: -1.5}
+	if err := s.Save("alice", want); err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.Load("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Key.ToURI() != want.Key.ToURI() || got.LastStep != want.LastStep || got.Failures != want.Failures || got.Drift != want.Drift {
+		t.Errorf("Expected %+v, got %+v", want, got)
+	}
+
+	if err := s.Delete("alice"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Load("alice"); err != ErrUnknownUser {
+		t.Errorf("Expected ErrUnknownUser after Delete, got %v", err)
+	}
+	if err := s.Delete("alice"); err != nil {
+		t.Errorf("Deleting a missing user failed: %v", err)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore())
+}
+
+func TestFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	testStore(t, NewFileStore(path))
+
+	if err := NewFileStore(path).Save("bob", Record{Key: storeKey}); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected mode 0600, got %v", info.Mode().Perm())
+	}
+
+	// a second store sees the first one's records
+	r, err := NewFileStore(path).Load("bob")
+	if err != nil || r.Key.Counter != 7 {
+		t.Errorf("Expected bob's record, got %+v (%v)", r, err)
+	}
+
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("Expected temporary files to be removed, got %v", entries)
+	}
+}
+
+func TestFileStoreCorrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	if err := os.WriteFile(path, []byte("{not json"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewFileStore(path).Load("alice"); err == nil || err == ErrUnknownUser {
+		t.Errorf("Expected a decoding error, got %v", err)
+	}
+}
@@ -0,0 +1,204 @@
+package otp
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// ErrThrottled is matched, using errors.Is, by the *ThrottledError returned
+// while a key is backing off or locked out.
+var ErrThrottled = errors.New("too many failed attempts")
+
+// ThrottledError reports when a throttled key may next be tried.
+type ThrottledError struct {
+	Until  time.Time // Time the next attempt is allowed.
+	Locked bool      // Whether the key is locked out rather than backing off.
+}
+
+func (e *ThrottledError) Error() string {
+	if e.Locked {
+		return fmt.Sprintf("%v; locked until %v", ErrThrottled, e.Until.Format(time.RFC3339))
+	}
+	return fmt.Sprintf("%v; retry after %v", ErrThrottled, e.Until.Format(time.RFC3339))
+}
+
+func (e *ThrottledError) Unwrap() error {
+	return ErrThrottled
+}
+
+// Attempts records the failed attempts for a key.
+type Attempts struct {
+	Failures int       // Failures since the last success or lockout.
+	Last     time.Time // Time of the latest failure.
+}
+
+// AttemptStore keeps the failed attempts of a Throttle's keys.
+// Implementations must be safe for concurrent use.
+type AttemptStore interface {
+	Get(key string) (Attempts, error) // Returns zero Attempts for an unknown key.
+	Put(key string, a Attempts) error
+	Reset(key string) error
+}
+
+// MemoryAttemptStore is an AttemptStore holding attempts in memory.
+type MemoryAttemptStore struct {
+	mu       sync.Mutex
+	attempts map[string]Attempts
+}
+
+// NewMemoryAttemptStore returns an empty MemoryAttemptStore.
+func NewMemoryAttemptStore() *MemoryAttemptStore {
+	return &MemoryAttemptStore{attempts: map[string]Attempts{}}
+}
+
+// Get returns the key's attempts.
+func (s *MemoryAttemptStore) Get(key string) (Attempts, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.attempts[key], nil
+}
+
+// Put stores the key's attempts.
+func (s *MemoryAttemptStore) Put(key string, a Attempts) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attempts[key] = a
+	return nil
+}
+
+// Reset forgets the key's attempts.
+func (s *MemoryAttemptStore) Reset(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.attempts, key)
+	return nil
+}
+
+// Throttle limits how quickly codes may be guessed. After each failure a
+// key must wait before its next attempt, the wait doubling with each failure
+// up to MaxDelay. Once LockoutAfter failures accumulate, the key is locked
+// for LockoutFor, after which it starts afresh. A success clears the
+// failures.
+type Throttle struct {
+	Store        AttemptStore
+	BaseDelay    time.Duration    // Wait after the first failure.
+	MaxDelay     time.Duration    // Longest wait between attempts before lockout; 0 is unlimited.
+	LockoutAfter int              // Failures that lock the key; 0 never locks.
+	LockoutFor   time.Duration    // How long a lockout lasts.
+	Now          func() time.Time // Clock. Defaults to time.Now.
+
+	keys keyLocks
+}
+
+// NewThrottle returns a throttle using the store that waits 1s after the
+// first failure, doubling up to 1m, and locks a key for 15m after 10
+// failures.
+func NewThrottle(store AttemptStore) *Throttle {
+	return &Throttle{
+		Store:        store,
+		BaseDelay:    time.Second,
+		MaxDelay:     time.Minute,
+		LockoutAfter: 10,
+		LockoutFor:   15 * time.Minute,
+		Now:          time.Now,
+	}
+}
+
+// now returns the current time from the throttle's clock.
+func (t *Throttle) now() time.Time {
+	if t.Now == nil {
+		return time.Now()
+	}
+	return t.Now()
+}
+
+// locked reports whether the attempts have reached the lockout.
+func (t *Throttle) locked(a Attempts) bool {
+	return t.LockoutAfter > 0 && a.Failures >= t.LockoutAfter
+}
+
+// delay returns the wait after the attempts' latest failure.
+func (t *Throttle) delay(a Attempts) time.Duration {
+	if a.Failures == 0 {
+		return 0
+	}
+	if t.locked(a) {
+		return t.LockoutFor
+	}
+	// stop doubling at the cap, or before overflowing without one
+	d := t.BaseDelay
+	for i := 1; i < a.Failures && d <= math.MaxInt64/2 && (t.MaxDelay <= 0 || d < t.MaxDelay); i++ {
+		d *= 2
+	}
+	if t.MaxDelay > 0 && d > t.MaxDelay {
+		d = t.MaxDelay
+	}
+	return d
+}
+
+// Allow returns a *ThrottledError if the key may not be tried yet.
+func (t *Throttle) Allow(key string) error {
+	defer t.keys.lock(key)()
+	_, err := t.allow(key)
+	return err
+}
+
+// allow returns the key's attempts, or a *ThrottledError if it may not be
+// tried yet. An expired lockout is cleared.
+func (t *Throttle) allow(key string) (Attempts, error) {
+	a, err := t.Store.Get(key)
+	if err != nil {
+		return a, err
+	}
+	until := a.Last.Add(t.delay(a))
+	if t.now().Before(until) {
+		return a, &ThrottledError{Until: until, Locked: t.locked(a)}
+	}
+	if t.locked(a) {
+		a = Attempts{}
+	}
+	return a, nil
+}
+
+// Failure records a failed attempt for the key.
+func (t *Throttle) Failure(key string) error {
+	defer t.keys.lock(key)()
+	a, err := t.allow(key)
+	if err != nil && !errors.Is(err, ErrThrottled) {
+		return err
+	}
+	return t.Store.Put(key, Attempts{Failures: a.Failures + 1, Last: t.now()})
+}
+
+// Success clears the key's failed attempts.
+func (t *Throttle) Success(key string) error {
+	defer t.keys.lock(key)()
+	return t.Store.Reset(key)
+}
+
+// Verify calls verify unless the key is throttled, then records the
+// outcome. Attempts on a key run one at a time, so parallel guesses can't
+// slip past the throttle. A false result counts as a failure, including one
+// with ErrReplayedCode; other errors leave the attempts as they were.
+func (t *Throttle) Verify(key string, verify func() (bool, error)) (bool, error) {
+	defer t.keys.lock(key)()
+
+	a, err := t.allow(key)
+	if err != nil {
+		return false, err
+	}
+
+	ok, err := verify()
+	switch {
+	case ok:
+		return true, t.Store.Reset(key)
+	case err == nil || errors.Is(err, ErrReplayedCode):
+		if perr := t.Store.Put(key, Attempts{Failures: a.Failures + 1, Last: t.now()}); perr != nil {
+			return false, perr
+		}
+	}
+	return false, err
+}
@@ -0,0 +1,213 @@
+package otp
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a clock for tests that only moves when told to.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func newTestThrottle() (*Throttle, *fakeClock) {
+	clock := &fakeClock{now: authNow}
+	t := NewThrottle(NewMemoryAttemptStore())
+	t.LockoutAfter = 4
+	t.Now = clock.Now
+	return t, clock
+}
+
+func wrongCode() (bool, error) {
+	return false, nil
+}
+
+func rightCode() (bool, error) {
+	return true, nil
+}
+
+func TestThrottleBackoff(t *testing.T) {
+	th, clock := newTestThrottle()
+
+	for i, wait := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second} {
+		if ok, err := th.Verify("alice", wrongCode); ok || err != nil {
+			t.Fatalf("Attempt %v: expected a plain failure, got %v %v", i+1, ok, err)
+		}
+
+		_, err := th.Verify("alice", rightCode)
+		var terr *ThrottledError
+		if !errors.As(err, &terr) || !errors.Is(err, ErrThrottled) || terr.Locked {
+			t.Fatalf("Attempt %v: expected a backoff, got %v", i+1, err)
+		}
+		if got := terr.Until.Sub(clock.Now()); got != wait {
+			t.Errorf("Attempt %v: expected to wait %v, got %v", i+1, wait, got)
+		}
+
+		clock.Advance(wait - time.Millisecond)
+		if err := th.Allow("alice"); err == nil {
+			t.Errorf("Attempt %v: allowed before the wait was over", i+1)
+		}
+		clock.Advance(time.Millisecond)
+	}
+
+	if ok, err := th.Verify("alice", rightCode); !ok || err != nil {
+		t.Errorf("Expected a success after waiting, got %v %v", ok, err)
+	}
+	if a, _ := th.Store.Get("alice"); a.Failures != 0 {
+		t.Errorf("Expected a success to reset failures, got %v", a.Failures)
+	}
+	if err := th.Allow("alice"); err != nil {
+		t.Errorf("Expected no wait after a success, got %v", err)
+	}
+}
+
+func TestThrottleMaxDelay(t *testing.T) {
+	th, clock := newTestThrottle()
+	th.LockoutAfter = 0
+	th.MaxDelay = 5 * time.Second
+
+	for i := 0; i < 20; i++ {
+		th.Failure("alice")
+		clock.Advance(time.Hour)
+	}
+	th.Failure("alice")
+	var terr *ThrottledError
+	if err := th.Allow("alice"); !errors.As(err, &terr) || terr.Until.Sub(clock.Now()) != 5*time.Second {
+		t.Errorf("Expected the wait to be capped at 5s, got %v", err)
+	}
+
+	th.MaxDelay = 0
+	if err := th.Allow("alice"); !errors.As(err, &terr) || terr.Until.Sub(clock.Now()) != 1<<20*time.Second {
+		t.Errorf("Expected the wait to keep doubling without a cap, got %v", err)
+	}
+}
+
+func TestThrottleUncappedOverflow(t *testing.T) {
+	th, clock := newTestThrottle()
+	th.LockoutAfter = 0
+	th.BaseDelay = time.Minute
+	th.MaxDelay = 0
+
+	for i := 0; i < 40; i++ {
+		th.Failure("alice")
+		clock.Advance(time.Second)
+	}
+	a, _ := th.Store.Get("alice")
+	if d := th.delay(a); d != time.Minute<<27 {
+		t.Errorf("Expected a huge wait after 40 failures, got %v", d)
+	}
+	if err := th.Allow("alice"); !errors.Is(err, ErrThrottled) {
+		t.Errorf("Expected to stay throttled, got %v", err)
+	}
+}
+
+func TestThrottleLockout(t *testing.T) {
+	th, clock := newTestThrottle()
+
+	for i := 0; i < 4; i++ {
+		if _, err := th.Verify("alice", wrongCode); err != nil {
+			t.Fatalf("Attempt %v: %v", i+1, err)
+		}
+		clock.Advance(time.Minute)
+	}
+
+	var terr *ThrottledError
+	if err := th.Allow("alice"); !errors.As(err, &terr) || !terr.Locked {
+		t.Fatalf("Expected a lockout, got %v", err)
+	}
+	if ok, err := th.Verify("alice", rightCode); ok || !errors.Is(err, ErrThrottled) {
+		t.Errorf("Expected a correct code to be refused while locked, got %v %v", ok, err)
+	}
+	if err := th.Allow("bob"); err != nil {
+		t.Errorf("Expected other keys to be unaffected, got %v", err)
+	}
+
+	clock.Advance(15 * time.Minute)
+	if err := th.Allow("alice"); err != nil {
+		t.Errorf("Expected the lockout to expire, got %v", err)
+	}
+	th.Verify("alice", wrongCode)
+	if a, _ := th.Store.Get("alice"); a.Failures != 1 {
+		t.Errorf("Expected failures to start afresh after a lockout, got %v", a.Failures)
+	}
+}
+
+func TestThrottleErrors(t *testing.T) {
+	th, _ := newTestThrottle()
+
+	boom := errors.New("boom")
+	if _, err := th.Verify("alice", func() (bool, error) { return false, boom }); err != boom {
+		t.Errorf("Expected the verify error, got %v", err)
+	}
+	if a, _ := th.Store.Get("alice"); a.Failures != 0 {
+		t.Errorf("Expected errors not to count as failures, got %v", a.Failures)
+	}
+
+	if _, err := th.Verify("alice", func() (bool, error) { return false, ErrReplayedCode }); err != ErrReplayedCode {
+		t.Errorf("Expected ErrReplayedCode, got %v", err)
+	}
+	if a, _ := th.Store.Get("alice"); a.Failures != 1 {
+		t.Errorf("Expected a replay to count as a failure, got %v", a.Failures)
+	}
+}
+
+func TestThrottleConcurrent(t *testing.T) {
+	th, _ := newTestThrottle()
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	tried := 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			th.Verify("alice", func() (bool, error) {
+				mu.Lock()
+				tried++
+				mu.Unlock()
+				return false, nil
+			})
+		}()
+	}
+	wg.Wait()
+
+	if tried != 1 {
+		t.Errorf("Expected one guess before the backoff, got %v", tried)
+	}
+}
+
+func TestAuthenticatorThrottle(t *testing.T) {
+	k := totpKey()
+	a := newTestAuthenticator(t, k)
+	th, clock := newTestThrottle()
+	a.Throttle = th
+	a.Now = clock.Now
+
+	if ok, err := a.Verify("alice", "000000"); ok || err != nil {
+		t.Fatalf("Expected a failure, got %v %v", ok, err)
+	}
+	code := mustCode(t, k, authNow.Unix()/30)
+	if ok, err := a.Verify("alice", code); ok || !errors.Is(err, ErrThrottled) {
+		t.Errorf("Expected the code to be refused during backoff, got %v %v", ok, err)
+	}
+
+	clock.Advance(time.Second)
+	if ok, err := a.Verify("alice", code); !ok || err != nil {
+		t.Errorf("Expected the code to be accepted after backoff, got %v %v", ok, err)
+	}
+}
@@ -5,7 +5,7 @@ import (
 	"crypto/sha1"
 	"crypto/sha256"
 	"crypto/sha512"
-	"errors"
+	"fmt"
 	"net/url"
 	"strconv"
 	"strings"
@@ -45,26 +45,36 @@ func (k Key) ToURI() string {
 
 // FromURI parses an otpauth URI into the key.
 // Defaults are included for the hashing algorithm (sha1.New), digits (6), and period (30); these parameters may be excluded from the URI. The issuer is optional. For totp, only the method, label, and secret are required. See https://code.google.com/p/google-authenticator/wiki/KeyUriFormat for more information.
+// A malformed URI or parameter is reported as a *ValidationError.
 //
 // Example:
 //      k.FromURI("otpauth://totp/Example:alice@google.com?algo=sha1&digits=6&issuer=Example&period=30&secret=NAR5XTDD3EQU22YU")
 func (k *Key) FromURI(uri string) error {
+	return k.fromURI(uri).err()
+}
+
+// fromURI parses the URI into the key, recording what is malformed.
+func (k *Key) fromURI(uri string) *ValidationError {
+	verr := &ValidationError{}
 
 	u, err := url.ParseRequestURI(uri)
 	if err != nil {
-		return err
+		verr.add("URI", fmt.Errorf("%w: %v", ErrInvalidURI, err))
+		return verr
 	}
 
 	if strings.ToLower(u.Scheme) != "otpauth" {
-		return errors.New("invalid scheme")
+		verr.add("URI", fmt.Errorf("%w: scheme is %q", ErrInvalidURI, u.Scheme))
+		return verr
 	}
 
 	(*k).Method = strings.ToLower(u.Host)
 
 	if len(u.Path) < 2 {
-		return errors.New("missing label")
+		verr.add("Label", ErrMissingLabel)
+	} else {
+		(*k).Label = u.Path[1:len(u.Path)]
 	}
-	(*k).Label = u.Path[1:len(u.Path)]
 
 	params := u.Query()
 	(*k).Secret32 = strings.ToUpper(params.Get("secret"))
@@ -85,7 +95,7 @@ func (k *Key) FromURI(uri string) error {
 	if digits != "" {
 		d, err := strconv.Atoi(digits)
 		if err != nil {
-			return errors.New("digits is non-integer")
+			verr.add("Digits", ErrInvalidDigits)
 		}
 		(*k).Digits = d
 	} else {
@@ -97,7 +107,7 @@ func (k *Key) FromURI(uri string) error {
 		if period != "" {
 			p, err := strconv.Atoi(period)
 			if err != nil {
-				return errors.New("period is non-integer")
+				verr.add("Period", ErrInvalidPeriod)
 			}
 			(*k).Period = p
 		} else {
@@ -108,11 +118,11 @@ func (k *Key) FromURI(uri string) error {
 		if counter != "" {
 			c, err := strconv.Atoi(counter)
 			if err != nil {
-				return errors.New("counter is non-integer")
+				verr.add("Counter", ErrInvalidCounter)
 			}
 			(*k).Counter = c
 		}
 	}
 
-	return nil
+	return verr
 }
@@ -5,6 +5,7 @@ import (
 	"crypto/sha1"
 	"crypto/sha256"
 	"crypto/sha512"
+	"errors"
 	"testing"
 )
 
@@ -201,3 +202,31 @@ func TestParseCounter(t *testing.T) {
 	}
 
 }
+
+func TestFromURIErrors(t *testing.T) {
+	tests := []struct {
+		uri   string
+		field string
+		err   error
+	}{
+		{"blahblah", "URI", ErrInvalidURI},
+		{"https://totp/label?secret=MFRGGZDFMZTWQ2LK", "URI", ErrInvalidURI},
+		{"otpauth://totp/?secret=MFRGGZDFMZTWQ2LK", "Label", ErrMissingLabel},
+		{"otpauth://totp/label?secret=MFRGGZDFMZTWQ2LK&digits=six", "Digits", ErrInvalidDigits},
+		{"otpauth://totp/label?secret=MFRGGZDFMZTWQ2LK&period=1m", "Period", ErrInvalidPeriod},
+		{"otpauth://hotp/label?secret=MFRGGZDFMZTWQ2LK&counter=x", "Counter", ErrInvalidCounter},
+	}
+
+	for _, test := range tests {
+		var k Key
+		err := k.FromURI(test.uri)
+		var verr *ValidationError
+		if !errors.As(err, &verr) || !errors.Is(err, test.err) {
+			t.Errorf("Expected %q from %v, got %v", test.err, test.uri, err)
+			continue
+		}
+		if verr.Fields[0].Field != test.field {
+			t.Errorf("Expected field %v from %v, got %v", test.field, test.uri, verr.Fields[0].Field)
+		}
+	}
+}
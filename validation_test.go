@@ -3,6 +3,7 @@ package otp
 import (
 	"code.google.com/p/go.crypto/md4"
 	"crypto/sha1"
+	"errors"
 	"testing"
 )
 
@@ -55,3 +56,52 @@ func TestBadKeys(t *testing.T) {
 		}
 	}
 }
+
+func TestValidationErrors(t *testing.T) {
+	tests := []struct {
+		k    Key
+		errs []error
+	}{
+		{BadKeys[0], []error{ErrInvalidMethod, ErrMissingLabel, ErrMissingSecret, ErrInvalidAlgo, ErrInvalidDigits}},
+		{BadKeys[3], []error{ErrInvalidSecret, ErrInvalidAlgo, ErrInvalidDigits, ErrInvalidPeriod}},
+		{BadKeys[4], []error{ErrInvalidAlgo, ErrInvalidDigits, ErrInvalidPeriod}},
+		{BadKeys[6], []error{ErrInvalidPeriod}},
+	}
+
+	for _, test := range tests {
+		err := test.k.Validate()
+		var verr *ValidationError
+		if !errors.As(err, &verr) {
+			t.Fatalf("Expected a *ValidationError for %v, got %v", test.k, err)
+		}
+		if len(verr.Fields) != len(test.errs) {
+			t.Errorf("Expected %v failing fields, got %v", len(test.errs), verr)
+		}
+		for _, want := range test.errs {
+			if !errors.Is(err, want) {
+				t.Errorf("Expected %v to include %q", err, want)
+			}
+		}
+	}
+}
+
+func TestValidationFieldError(t *testing.T) {
+	k := Key{Method: "totp", Label: "t@w", Secret32: "abc123", Algo: sha1.New, Digits: 6, Period: 30}
+	err := k.Validate()
+
+	var field FieldError
+	if !errors.As(err, &field) || field.Field != "Secret32" || field.Err != ErrInvalidSecret {
+		t.Errorf("Expected the secret to fail, got %#v", field)
+	}
+	if errors.Is(err, ErrInvalidDigits) {
+		t.Error("Valid digits reported as failing")
+	}
+	if err.Error() != ErrInvalidSecret.Error() {
+		t.Errorf("Unexpected message %q", err)
+	}
+
+	k.Secret32 = "MFRGGZDFMZTWQ2LK"
+	if err := k.Validate(); err != nil {
+		t.Errorf("Expected a nil error for a valid key, got %#v", err)
+	}
+}
@@ -0,0 +1,241 @@
+/*
+Package yubikey reads and writes the CSV logs the YubiKey Personalization
+Tool keeps of the slots it programs, so keys provisioned in OATH-HOTP mode
+can be imported as otp.Key values.
+
+Two log formats are read. The Traditional format has a row per event:
+
+	event, timestamp, slot, public ID, private ID, secret, current access code,
+	new access code, OATH enabled, fixed modhex 1, fixed modhex 2, fixed modhex,
+	HOTP digits, moving factor seed, strong password 1, strong password 2,
+	send reference, button trigger, HMAC less than 64 bytes
+
+Only its OATH-HOTP rows are imported; rows for other modes are skipped.
+Secrets are hex. Writing a log keeps each slot's flags as they were read,
+but leaves the private ID and access codes empty.
+
+The tool's other format, Yubico, is not read. It is written for uploading
+Yubico OTP slots to a validation server, so its secret is a 16-byte AES key
+rather than an HOTP seed, and it records neither the mode nor digits nor
+counter:
+
+	serial, public ID, private ID, secret, access code, timestamp,
+
+ReadCSV returns ErrYubicoFormat for such a log, rather than keys that would
+never verify.
+*/
+package yubikey
+
+import (
+	"crypto/sha1"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"github.com/tristanwietsma/otp"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Issuer is the issuer of imported keys.
+const Issuer = "YubiKey"
+
+// eventHOTP is the event of Traditional rows programming OATH-HOTP.
+const eventHOTP = "OATH-HOTP"
+
+// traditionalColumns is the column count of the Traditional format.
+const traditionalColumns = 19
+
+// Errors returned by ReadCSV.
+var (
+	ErrNoKeys       = errors.New("no OATH-HOTP slots in log")
+	ErrYubicoFormat = errors.New("log is in the Yubico format, which records Yubico OTP slots; log OATH-HOTP slots in the Traditional format")
+)
+
+// Entry is a slot programmed in OATH-HOTP mode.
+type Entry struct {
+	Slot      int     // Configuration slot, 1 or 2.
+	PublicID  string  // Token identifier, in modhex, sent before each code.
+	Timestamp string  // When the slot was programmed, as logged.
+	Key       otp.Key // The hotp key, with the counter the slot started at.
+	Flags     Flags   // The slot's configuration flags, as logged.
+}
+
+// Flags are the flag columns of a Traditional row, kept as logged so that
+// WriteCSV writes them back unchanged. Each is "0" or "1"; an empty flag is
+// written as 1 for OATHEnabled and 0 for the rest.
+type Flags struct {
+	OATHEnabled     string
+	FixedModhex1    string
+	FixedModhex2    string
+	FixedModhex     string
+	StrongPassword1 string
+	StrongPassword2 string
+	SendReference   string
+	ButtonTrigger   string
+	ShortHMAC       string // HMAC less than 64 bytes.
+}
+
+// Label returns a label naming the slot: its token identifier or, failing
+// that, its slot number.
+func (e Entry) Label() string {
+	if e.PublicID != "" {
+		return "yubikey-" + e.PublicID
+	}
+	label := "yubikey"
+	if e.Slot != 0 {
+		label += "-slot" + strconv.Itoa(e.Slot)
+	}
+	return label
+}
+
+// ReadCSV returns the OATH-HOTP slots in a log in the Traditional format.
+// Blank lines and lines starting with # are ignored. A malformed row stops
+// the import with an error naming its line.
+func ReadCSV(r io.Reader) ([]Entry, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.Comment = '#'
+	cr.TrimLeadingSpace = true
+
+	entries := []Entry{}
+	for {
+		row, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := cr.FieldPos(0)
+
+		e, ok, err := parseRow(row)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if ok {
+			entries = append(entries, e)
+		}
+	}
+	if len(entries) == 0 {
+		return nil, ErrNoKeys
+	}
+	return entries, nil
+}
+
+// parseRow returns the slot a row programs, reporting false for rows of
+// other modes.
+func parseRow(row []string) (Entry, bool, error) {
+	for i := range row {
+		row[i] = strings.TrimSpace(row[i])
+	}
+
+	// only Yubico rows start with a serial number
+	if _, err := strconv.Atoi(row[0]); err == nil {
+		return Entry{}, false, ErrYubicoFormat
+	}
+	if row[0] != eventHOTP {
+		return Entry{}, false, nil
+	}
+	return parseTraditional(row)
+}
+
+// parseTraditional parses an OATH-HOTP row of the Traditional format.
+func parseTraditional(row []string) (Entry, bool, error) {
+	if len(row) != traditionalColumns {
+		return Entry{}, false, fmt.Errorf("expected %d columns, got %d", traditionalColumns, len(row))
+	}
+
+	slot, err := strconv.Atoi(row[2])
+	if err != nil || slot < 1 || slot > 2 {
+		return Entry{}, false, fmt.Errorf("slot %q is not 1 or 2", row[2])
+	}
+	digits, err := strconv.Atoi(row[12])
+	if err != nil {
+		return Entry{}, false, fmt.Errorf("digits %q is not a number", row[12])
+	}
+	counter, err := strconv.Atoi(row[13])
+	if err != nil || counter < 0 {
+		return Entry{}, false, fmt.Errorf("moving factor seed %q is not a counter", row[13])
+	}
+
+	e := Entry{Slot: slot, PublicID: row[3], Timestamp: row[1]}
+	e.Flags = Flags{row[8], row[9], row[10], row[11], row[14], row[15], row[16], row[17], row[18]}
+	return e, true, e.setKey(row[5], digits, counter)
+}
+
+// setKey sets the entry's key from its hex secret and validates it.
+func (e *Entry) setKey(secretHex string, digits, counter int) error {
+	secret, err := otp.SecretFromHex(secretHex)
+	if err != nil {
+		return err
+	}
+	e.Key = otp.Key{
+		Method:  "hotp",
+		Label:   e.Label(),
+		Issuer:  Issuer,
+		Algo:    sha1.New,
+		Digits:  digits,
+		Counter: counter,
+	}
+	e.Key.SetSecret(secret)
+	return e.Key.Validate()
+}
+
+// WriteCSV writes the entries as a log in the Traditional format, which the
+// Personalization Tool and ReadCSV both read. Keys must be hotp with SHA-1,
+// the only hash a YubiKey's OATH-HOTP mode supports.
+func WriteCSV(w io.Writer, entries []Entry) error {
+	cw := csv.NewWriter(w)
+	for _, e := range entries {
+		row, err := formatTraditional(e)
+		if err != nil {
+			return err
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// formatTraditional returns the Traditional row programming the entry.
+func formatTraditional(e Entry) ([]string, error) {
+	k := e.Key
+	if err := k.Validate(); err != nil {
+		return nil, fmt.Errorf("%v: %w", k.Label, err)
+	}
+	if k.Method != "hotp" {
+		return nil, fmt.Errorf("%v: YubiKeys only support hotp in OATH mode", k.Label)
+	}
+	// SHA-1 is the only supported hash with its digest size
+	if k.Algo().Size() != sha1.Size {
+		return nil, fmt.Errorf("%v: YubiKeys only support SHA-1 in OATH mode", k.Label)
+	}
+	secret, err := k.Secret()
+	if err != nil {
+		return nil, err
+	}
+
+	slot := e.Slot
+	if slot == 0 {
+		slot = 1
+	}
+	f := e.Flags
+	return []string{
+		eventHOTP, e.Timestamp, strconv.Itoa(slot), e.PublicID, "", secret.Hex(), "", "",
+		flag(f.OATHEnabled, "1"), flag(f.FixedModhex1, "0"), flag(f.FixedModhex2, "0"), flag(f.FixedModhex, "0"),
+		strconv.Itoa(k.Digits), strconv.Itoa(k.Counter),
+		flag(f.StrongPassword1, "0"), flag(f.StrongPassword2, "0"), flag(f.SendReference, "0"),
+		flag(f.ButtonTrigger, "0"), flag(f.ShortHMAC, "0"),
+	}, nil
+}
+
+// flag returns the flag's value, or its default if it is empty.
+func flag(value, def string) string {
+	if value == "" {
+		return def
+	}
+	return value
+}
@@ -0,0 +1,140 @@
+package yubikey
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"errors"
+	"github.com/tristanwietsma/otp"
+	"os"
+	"strings"
+	"testing"
+)
+
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func readFixture(t *testing.T, name string) []Entry {
+	f, err := os.Open("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	entries, err := ReadCSV(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return entries
+}
+
+func TestReadTraditional(t *testing.T) {
+	entries := readFixture(t, "traditional.csv")
+	if len(entries) != 2 {
+		t.Fatalf("Expected the 2 OATH-HOTP rows, got %v", len(entries))
+	}
+
+	e := entries[0]
+	if e.Slot != 2 || e.PublicID != "" || e.Timestamp != "2015-02-12T10:10:42" {
+		t.Errorf("Unexpected entry %+v", e)
+	}
+	k := e.Key
+	if k.Method != "hotp" || k.Label != "yubikey-slot2" || k.Issuer != Issuer || k.Secret32 != rfcSecret || k.Digits != 6 || k.Counter != 0 {
+		t.Errorf("Unexpected key %+v", k)
+	}
+	if code, _ := k.GetCode(int64(k.Counter)); code != "755224" {
+		t.Errorf("Expected the RFC 4226 code 755224, got %v", code)
+	}
+
+	e = entries[1]
+	if e.Slot != 1 || e.PublicID != "cccccccccccb" || e.Key.Label != "yubikey-cccccccccccb" || e.Key.Digits != 8 || e.Key.Counter != 16 {
+		t.Errorf("Unexpected entry %+v", e)
+	}
+	if e.Flags.OATHEnabled != "1" || e.Flags.FixedModhex1 != "1" || e.Flags.FixedModhex != "0" {
+		t.Errorf("Unexpected flags %+v", e.Flags)
+	}
+	if s, _ := e.Key.Secret(); s.Hex() != "a1b2c3d4e5f60718293a4b5c6d7e8f9012345678" {
+		t.Errorf("Unexpected secret %v", s.Hex())
+	}
+	if code, _ := e.Key.GetCode(int64(e.Key.Counter)); code != "31765813" {
+		t.Errorf("Expected the token's 8-digit code 31765813, got %v", code)
+	}
+}
+
+func TestReadYubico(t *testing.T) {
+	f, err := os.Open("testdata/yubico.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := ReadCSV(f); !errors.Is(err, ErrYubicoFormat) {
+		t.Errorf("Expected ErrYubicoFormat, got %v", err)
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"OATH-HOTP,2015,1,,,3132,,,1,0,0,0,6,0\n", "line 1: expected 19 columns, got 14"},
+		{"\nOATH-HOTP,2015,3,,,3132,,,1,0,0,0,6,0,0,0,0,0,0\n", `line 2: slot "3" is not 1 or 2`},
+		{"OATH-HOTP,2015,1,,,3132,,,1,0,0,0,six,0,0,0,0,0,0\n", `line 1: digits "six" is not a number`},
+		{"OATH-HOTP,2015,1,,,3132,,,1,0,0,0,6,-1,0,0,0,0,0\n", `line 1: moving factor seed "-1" is not a counter`},
+		{"OATH-HOTP,2015,1,,,3132,,,1,0,0,0,7,0,0,0,0,0,0\n", "line 1: digits is not 6 or 8"},
+		{"OATH-HOTP,2015,1,,,313,,,1,0,0,0,6,0,0,0,0,0,0\n", "line 1: secret is not valid hex"},
+		{"Yubico OTP,2015,1,,,3132,,,0,0,0,0,0,0,0,0,0,0,0\n", ErrNoKeys.Error()},
+	}
+	for _, tt := range tests {
+		_, err := ReadCSV(strings.NewReader(tt.in))
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("%q: expected %q, got %v", tt.in, tt.want, err)
+		}
+	}
+
+	_, err := ReadCSV(strings.NewReader("OATH-HOTP,2015,1,,,313,,,1,0,0,0,6,0,0,0,0,0,0\n"))
+	if !errors.Is(err, otp.ErrInvalidHex) {
+		t.Errorf("Expected ErrInvalidHex, got %v", err)
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	want, err := os.ReadFile("testdata/export.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, readFixture(t, "traditional.csv")); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != string(want) {
+		t.Errorf("Expected\n%s\ngot\n%s", want, buf.String())
+	}
+
+	entries, err := ReadCSV(&buf)
+	original := readFixture(t, "traditional.csv")
+	if err != nil || len(entries) != 2 || entries[1].Key.ToURI() != original[1].Key.ToURI() || entries[1].Flags != original[1].Flags {
+		t.Errorf("Expected the export to read back, got %+v %v", entries, err)
+	}
+
+	// new entries are written with the tool's defaults
+	buf.Reset()
+	k, _ := otp.NewHOTPKey("new", rfcSecret, "", sha1.New, 6, 0)
+	if err := WriteCSV(&buf, []Entry{{Key: *k}}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(buf.String(), ",,,1,0,0,0,6,0,0,0,0,0,0\n") {
+		t.Errorf("Unexpected default flags in %q", buf.String())
+	}
+}
+
+func TestWriteErrors(t *testing.T) {
+	totp, _ := otp.NewTOTPKey("totp", rfcSecret, "", sha1.New, 6, 30)
+	sha, _ := otp.NewHOTPKey("sha", rfcSecret, "", sha256.New, 6, 0)
+	bad := otp.Key{Method: "hotp", Label: "bad", Secret32: "abc123"}
+
+	for _, k := range []otp.Key{*totp, *sha, bad} {
+		if err := WriteCSV(&bytes.Buffer{}, []Entry{{Key: k}}); err == nil || !strings.HasPrefix(err.Error(), k.Label+": ") {
+			t.Errorf("Expected %v to be refused, got %v", k.Label, err)
+		}
+	}
+}